@@ -10,9 +10,22 @@ type CLI struct {
 		Update UpdateCommand `cmd:"" help:"Update an existing secret"`
 		Delete DeleteCommand `cmd:"" help:"Delete a secret"`
 		Export ExportCommand `cmd:"" help:"Export secrets as environment variables"`
+		Render RenderCommand `cmd:"" help:"Render templates with secrets"`
 
 		VaultID string `help:"Vault ID" required:"" env:"VAULT_ID"`
 	} `cmd:"" help:"Manage secrets in Sakura Secret Manager"`
 
+	Vault struct {
+		List   VaultListCommand   `cmd:"" help:"List vaults"`
+		Get    VaultGetCommand    `cmd:"" help:"Get vault details"`
+		Create VaultCreateCommand `cmd:"" help:"Create a new vault"`
+		Update VaultUpdateCommand `cmd:"" help:"Update an existing vault"`
+		Delete VaultDeleteCommand `cmd:"" help:"Delete a vault"`
+	} `cmd:"" help:"Manage vaults in Sakura Secret Manager"`
+
+	Inject InjectCommand `cmd:"" help:"Resolve sakura:// references to secrets in files and environment variables"`
+
+	APIOptions `embed:"" group:"API requests"`
+
 	Version kong.VersionFlag `short:"v" help:"Show version and exit."`
 }
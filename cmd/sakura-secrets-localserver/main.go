@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/fujiwara/sakura-secrets-cli/localserver"
 )
@@ -15,14 +18,75 @@ import (
 func main() {
 	addr := flag.String("addr", ":8080", "listen address")
 	prefix := flag.String("prefix", "/api/cloud/1.1", "URL path prefix")
+	strict := flag.Bool("strict", false, "reject secret operations against vaults that were never created")
+	dataDir := flag.String("data-dir", "", "directory to persist data in (data is in-memory only if neither -data-dir nor -data-file is set)")
+	dataFile := flag.String("data-file", "", "file to persist data in")
+	masterKey := flag.String("master-key", "", "base64 encoded 32 bytes master key to encrypt secrets (env: "+masterKeyEnv+")")
+	masterKeyFile := flag.String("master-key-file", "", "file containing the master key")
+	seed := flag.String("seed", "", "YAML or JSON seed file, or a directory of seed files (one per vault), to load at startup")
+	admin := flag.Bool("admin", false, "enable the admin API under "+localserver.AdminPrefix)
+	accessToken := flag.String("access-token", "", "require HTTP basic auth with this access token (with -access-token-secret)")
+	accessTokenSecret := flag.String("access-token-secret", "", "access token secret for -access-token")
+	validate := flag.Bool("validate", false, "validate requests against the OpenAPI spec and respond with 400 for invalid ones")
+	validateResponses := flag.Bool("validate-responses", false, "validate responses against the OpenAPI spec as well (implies -validate)")
+	maxPageSize := flag.Int("max-page-size", 0, "maximum number of items returned by a list operation (0 means no limit)")
+	credentialsFile := flag.String("credentials-file", "", "YAML or JSON file of credentials to require with HTTP basic auth")
+	var faults localserver.FaultConfig
+	flag.Func("latency", "latency added to every API response (e.g. 100ms)", durationFlag(&faults.Latency))
+	flag.Func("jitter", "random latency between 0 and this value added to every API response", durationFlag(&faults.Jitter))
+	flag.Func("fault", "fault injection rule like 'status=503,rate=0.1,retry-after=2,op=OPERATION_ID' or 'drop,rate=0.5' (repeatable)", func(s string) error {
+		rule, err := localserver.ParseFaultRule(s)
+		if err != nil {
+			return err
+		}
+		faults.Rules = append(faults.Rules, rule)
+		return nil
+	})
 	flag.Parse()
 
+	opts := []localserver.Option{localserver.WithFaults(faults)}
+	if *strict {
+		opts = append(opts, localserver.WithStrictVaults())
+	}
+	if *admin {
+		opts = append(opts, localserver.WithAdmin())
+	}
+	if *maxPageSize > 0 {
+		opts = append(opts, localserver.WithMaxPageSize(*maxPageSize))
+	}
+	if *validate || *validateResponses {
+		opts = append(opts, localserver.WithValidation(*validateResponses))
+	}
+	creds, err := loadCredentials(*accessToken, *accessTokenSecret, *credentialsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(creds) > 0 {
+		log.Printf("basic auth is required with %d credentials", len(creds))
+		opts = append(opts, localserver.WithCredentials(creds...))
+	}
+	backend, err := newBackend(*dataDir, *dataFile, *masterKey, *masterKeyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts = append(opts, localserver.WithBackend(backend))
+	if *seed != "" {
+		sd, err := localserver.LoadSeed(*seed)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := sd.Apply(backend); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("seeded %s from %s", sd.Summary(), *seed)
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
 	srv := &http.Server{
 		Addr:    *addr,
-		Handler: localserver.NewServer(*prefix),
+		Handler: localserver.NewServer(*prefix, opts...),
 	}
 
 	go func() {
@@ -42,8 +106,14 @@ func main() {
 	fmt.Fprintln(log.Writer(), "To connect sakura-secrets-cli to this server, set the following environment variables:")
 	fmt.Fprintln(log.Writer(), "")
 	fmt.Fprintf(log.Writer(), "  export SAKURA_API_ROOT_URL=%s\n", rootURL)
-	fmt.Fprintln(log.Writer(), "  export SAKURA_ACCESS_TOKEN=dummy")
-	fmt.Fprintln(log.Writer(), "  export SAKURA_ACCESS_TOKEN_SECRET=dummy")
+	if len(creds) > 0 {
+		// never print the secret to logs
+		fmt.Fprintf(log.Writer(), "  export SAKURA_ACCESS_TOKEN=%s\n", creds[0].AccessToken)
+		fmt.Fprintln(log.Writer(), "  export SAKURA_ACCESS_TOKEN_SECRET=<secret of the access token>")
+	} else {
+		fmt.Fprintln(log.Writer(), "  export SAKURA_ACCESS_TOKEN=dummy")
+		fmt.Fprintln(log.Writer(), "  export SAKURA_ACCESS_TOKEN_SECRET=dummy")
+	}
 	fmt.Fprintln(log.Writer(), "  export VAULT_ID=your-vault-id")
 	fmt.Fprintln(log.Writer(), "")
 
@@ -51,3 +121,85 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// loadCredentials returns the credentials given by the flags.
+// No credentials means authentication is disabled.
+func loadCredentials(accessToken, accessTokenSecret, credentialsFile string) ([]localserver.Credential, error) {
+	var creds []localserver.Credential
+	if (accessToken == "") != (accessTokenSecret == "") {
+		return nil, errors.New("-access-token and -access-token-secret must be given together")
+	}
+	if accessToken != "" {
+		creds = append(creds, localserver.Credential{
+			AccessToken:       accessToken,
+			AccessTokenSecret: accessTokenSecret,
+		})
+	}
+	if credentialsFile != "" {
+		c, err := localserver.LoadCredentials(credentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, c...)
+	}
+	return creds, nil
+}
+
+func durationFlag(d *localserver.Duration) func(string) error {
+	return func(s string) error {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		if v < 0 {
+			return errors.New("must not be negative")
+		}
+		*d = localserver.Duration(v)
+		return nil
+	}
+}
+
+const masterKeyEnv = "SAKURA_SECRETS_LOCALSERVER_MASTER_KEY"
+
+// newBackend creates the storage backend from the command line options.
+func newBackend(dataDir, dataFile, masterKey, masterKeyFile string) (localserver.Backend, error) {
+	if dataDir != "" && dataFile != "" {
+		return nil, errors.New("-data-dir and -data-file are mutually exclusive")
+	}
+	key, err := loadMasterKey(masterKey, masterKeyFile, dataDir != "" || dataFile != "")
+	if err != nil {
+		return nil, err
+	}
+	var fst *localserver.FileStore
+	switch {
+	case dataDir != "":
+		fst, err = localserver.NewFileStoreInDir(dataDir, key)
+	case dataFile != "":
+		fst, err = localserver.NewFileStore(dataFile, key)
+	default:
+		return localserver.NewStoreWithKey(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("persisting data to %s", fst.Path())
+	return fst, nil
+}
+
+// loadMasterKey resolves the master key from the flag, the environment variable or the key file, in this order.
+// A master key is required to persist data, so that the data can be loaded on the next start.
+// Otherwise a random key is generated if none is given.
+func loadMasterKey(masterKey, masterKeyFile string, persist bool) ([]byte, error) {
+	if masterKey == "" {
+		masterKey = os.Getenv(masterKeyEnv)
+	}
+	switch {
+	case masterKey != "":
+		return localserver.ParseMasterKey(masterKey)
+	case masterKeyFile != "":
+		return localserver.LoadMasterKeyFile(masterKeyFile)
+	case persist:
+		return nil, fmt.Errorf("-master-key, -master-key-file or %s is required with -data-dir or -data-file (generate a key with `openssl rand -base64 32`)", masterKeyEnv)
+	}
+	return localserver.GenerateMasterKey()
+}
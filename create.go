@@ -18,10 +18,6 @@ type CreateCommand struct {
 
 func runCreateCommand(ctx context.Context, cli *CLI) error {
 	cmd := cli.Secret.Create
-	client, err := newSMClient()
-	if err != nil {
-		return fmt.Errorf("failed to create SecretManager client: %w", err)
-	}
 	value := cmd.Value
 	if cmd.Stdin {
 		b, err := io.ReadAll(os.Stdin)
@@ -31,6 +27,13 @@ func runCreateCommand(ctx context.Context, cli *CLI) error {
 		value = string(b)
 	}
 
+	// the deadline starts after reading stdin, not to count the time to input
+	ctx, cancel := cli.APIOptions.withDeadline(ctx)
+	defer cancel()
+	client, err := newSMClient(cli.APIOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create SecretManager client: %w", err)
+	}
 	secOp := sm.NewSecretOp(client, cli.Secret.VaultID)
 	res, err := secOp.Create(ctx, v1.CreateSecret{
 		Name:  cmd.Name,
@@ -24,7 +24,10 @@ func runDeleteCommand(ctx context.Context, cli *CLI) error {
 		return nil
 	}
 
-	client, err := newSMClient()
+	// the deadline starts after the confirmation, not to count the time to answer
+	ctx, cancel := cli.APIOptions.withDeadline(ctx)
+	defer cancel()
+	client, err := newSMClient(cli.APIOptions)
 	if err != nil {
 		return fmt.Errorf("failed to create SecretManager client: %w", err)
 	}
@@ -0,0 +1,195 @@
+package sscli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	// DefaultExpandSeparator joins keys of nested objects.
+	DefaultExpandSeparator = "_"
+
+	// ArraysIndex expands arrays to a key per element with the index, like KEY_0.
+	ArraysIndex = "index"
+	// ArraysJSON expands arrays to a key with the JSON encoded array.
+	ArraysJSON = "json"
+)
+
+// expandValue parses the secret value in the format, and flattens it into keys and string values.
+// Keys of nested objects are joined with separator.
+// Numbers and booleans are stringified, and null is an empty string.
+func expandValue(value, format, separator, arrays string) (map[string]string, error) {
+	if format == "dotenv" {
+		return parseDotenv(value)
+	}
+	var data any
+	switch format {
+	case "json":
+		data = []byte(value)
+	case "yaml":
+		if err := yaml.Unmarshal([]byte(value), &data); err != nil {
+			return nil, fmt.Errorf("failed to parse as YAML: %w", err)
+		}
+	case "toml":
+		var m map[string]any
+		if _, err := toml.Decode(value, &m); err != nil {
+			return nil, fmt.Errorf("failed to parse as TOML: %w", err)
+		}
+		data = m
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	// normalize values of all formats into the types of JSON
+	b, ok := data.([]byte)
+	if !ok {
+		var err error
+		if b, err = json.Marshal(data); err != nil {
+			return nil, fmt.Errorf("failed to convert %s to JSON: %w", format, err)
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var obj map[string]any
+	if err := dec.Decode(&obj); err != nil || obj == nil {
+		return nil, fmt.Errorf("value is not a %s object", format)
+	}
+
+	f := flattener{separator: separator, arraysJSON: arrays == ArraysJSON, out: make(map[string]string)}
+	if f.separator == "" {
+		f.separator = DefaultExpandSeparator
+	}
+	for k, v := range obj {
+		if err := f.flatten(k, v); err != nil {
+			return nil, err
+		}
+	}
+	return f.out, nil
+}
+
+type flattener struct {
+	separator  string
+	arraysJSON bool
+	out        map[string]string
+}
+
+func (f *flattener) flatten(key string, v any) error {
+	switch v := v.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			f.out[key] = "{}"
+		}
+		for k, e := range v {
+			if err := f.flatten(key+f.separator+k, e); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if f.arraysJSON || len(v) == 0 {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("failed to encode %s as JSON: %w", key, err)
+			}
+			f.out[key] = string(b)
+			return nil
+		}
+		for i, e := range v {
+			if err := f.flatten(key+f.separator+strconv.Itoa(i), e); err != nil {
+				return err
+			}
+		}
+	case string:
+		f.out[key] = v
+	case nil:
+		f.out[key] = ""
+	default: // json.Number, bool
+		f.out[key] = fmt.Sprint(v)
+	}
+	return nil
+}
+
+var dotenvUnescapeReplacer = strings.NewReplacer(
+	`\\`, `\`,
+	`\"`, `"`,
+	`\$`, `$`,
+	`\n`, "\n",
+	`\r`, "\r",
+	`\t`, "\t",
+)
+
+// parseDotenv parses KEY=VALUE lines of a .env file.
+// Values can be single quoted (literal), double quoted (with escapes and newlines) or unquoted.
+func parseDotenv(s string) (map[string]string, error) {
+	envs := make(map[string]string)
+	sc := bufio.NewScanner(strings.NewReader(s))
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		// trailing spaces are kept for a quoted value continuing to the next line
+		line := strings.TrimLeft(sc.Text(), " \t")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid dotenv line %d: KEY=VALUE is expected", lineNo)
+		}
+		value = strings.TrimLeft(value, " \t")
+		if strings.TrimSpace(value) == "" {
+			envs[key] = ""
+			continue
+		}
+		switch q := value[0]; q {
+		case '\'', '"':
+			// a quoted value may continue to the following lines
+			start := lineNo
+			for closingQuote(value[1:], q) < 0 {
+				if !sc.Scan() {
+					return nil, fmt.Errorf("invalid dotenv line %d: unterminated quote", start)
+				}
+				lineNo++
+				value += "\n" + sc.Text()
+			}
+			end := closingQuote(value[1:], q) + 1
+			if rest := strings.TrimSpace(value[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, fmt.Errorf("invalid dotenv line %d: unexpected characters after the quoted value", lineNo)
+			}
+			if q == '\'' {
+				envs[key] = value[1:end]
+			} else {
+				envs[key] = dotenvUnescapeReplacer.Replace(value[1:end])
+			}
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = value[:i]
+			}
+			envs[key] = strings.TrimSpace(value)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return envs, nil
+}
+
+// closingQuote returns the index of the closing quote q in s, or -1.
+// Backslashes escape the next character in double quotes.
+func closingQuote(s string, q byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && q == '"':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,115 @@
+package sscli
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestExpandValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		format    string
+		separator string
+		arrays    string
+		want      map[string]string
+	}{
+		{
+			name:   "flat json",
+			value:  `{"host":"localhost","password":"secret"}`,
+			format: "json",
+			want:   map[string]string{"host": "localhost", "password": "secret"},
+		},
+		{
+			name:   "nested json",
+			value:  `{"db":{"host":"localhost","port":5432,"ssl":true,"replica":null},"tags":["a","b"],"big":12345678901234567890,"pi":3.14,"empty":{}}`,
+			format: "json",
+			want: map[string]string{
+				"db_host": "localhost", "db_port": "5432", "db_ssl": "true", "db_replica": "",
+				"tags_0": "a", "tags_1": "b", "big": "12345678901234567890", "pi": "3.14", "empty": "{}",
+			},
+		},
+		{
+			name:      "separator and arrays as json",
+			value:     `{"db":{"hosts":["a","b"],"opts":[{"k":1}]}}`,
+			format:    "json",
+			separator: "__",
+			arrays:    ArraysJSON,
+			want:      map[string]string{"db__hosts": `["a","b"]`, "db__opts": `[{"k":1}]`},
+		},
+		{
+			name:   "yaml",
+			value:  "db:\n  host: localhost\n  port: 5432\nflags:\n  - x\n  - y\nenabled: true\n",
+			format: "yaml",
+			want:   map[string]string{"db_host": "localhost", "db_port": "5432", "flags_0": "x", "flags_1": "y", "enabled": "true"},
+		},
+		{
+			name:   "toml",
+			value:  "name = \"app\"\nratio = 0.5\n[db]\nhost = \"localhost\"\nport = 5432\n[[servers]]\nip = \"10.0.0.1\"\n",
+			format: "toml",
+			want:   map[string]string{"name": "app", "ratio": "0.5", "db_host": "localhost", "db_port": "5432", "servers_0_ip": "10.0.0.1"},
+		},
+		{
+			name:   "dotenv",
+			value:  "# comment\nexport HOST=localhost # inline comment\nSINGLE='it is $literal'\nDOUBLE=\"a\\nb \\\"q\\\" \\$x\"\nMULTI=\"line1\nline2\"\nEMPTY=\n",
+			format: "dotenv",
+			want: map[string]string{
+				"HOST": "localhost", "SINGLE": "it is $literal", "DOUBLE": "a\nb \"q\" $x", "MULTI": "line1\nline2", "EMPTY": "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandValue(tt.value, tt.format, tt.separator, tt.arrays)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandValueErrors(t *testing.T) {
+	tests := []struct {
+		value  string
+		format string
+	}{
+		{value: `not json`, format: "json"},
+		{value: `["a"]`, format: "json"},
+		{value: `null`, format: "json"},
+		{value: "- a\n- b\n", format: "yaml"},
+		{value: "a = ", format: "toml"},
+		{value: "NO_EQUAL\n", format: "dotenv"},
+		{value: "A=\"unterminated\n", format: "dotenv"},
+		{value: "A='x' y\n", format: "dotenv"},
+	}
+	for _, tt := range tests {
+		if got, err := expandValue(tt.value, tt.format, "", ""); err == nil {
+			t.Errorf("expected error for %s %q, got %v", tt.format, tt.value, got)
+		}
+	}
+}
+
+func TestDotenvRoundTrip(t *testing.T) {
+	envs := map[string]string{
+		"PLAIN":  "value",
+		"QUOTE":  `it's "quoted" \ $HOME`,
+		"LINES":  "line1\nline2\r\n",
+		"SPACES": "  padded  ",
+	}
+	for k, v := range envs {
+		line, err := formatDotenv(k, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := parseDotenv(line + "\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got[k] != v {
+			t.Errorf("%s: got %q, want %q (line %q)", k, got[k], v, line)
+		}
+	}
+}
@@ -1,58 +1,216 @@
 package sscli
 
 import (
+	"bytes"
+	"cmp"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"maps"
 	"os"
 	"os/exec"
 	"regexp"
-	"strconv"
+	"slices"
 	"strings"
 	"syscall"
 
-	sm "github.com/sacloud/secretmanager-api-go"
-	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
+	apiclient "github.com/sacloud/api-client-go"
+
+	"github.com/fujiwara/sakura-secrets-cli/internal/atomicfile"
 )
 
 type ExportCommand struct {
-	Name     []string `help:"Names of the secrets to export. You can specify version and options like 'name:version:json:prefix'." required:""`
-	Commands []string `arg:"" help:"Command to run with exported secrets in environment variables" optional:""`
+	Name        []string `help:"Names of the secrets to export. You can specify version and options like 'name:version:json:prefix', the variable name like 'ENV_VAR=name', and a JSON key like 'name#key'."`
+	OnConflict  string   `help:"What to do when secrets set the same environment variable (error, first, last)" enum:"error,first,last" default:"error"`
+	Concurrency int      `help:"Number of secrets to fetch concurrently" default:"${default_export_concurrency}"`
+	KeepCase    bool     `help:"Keep the case of secret names and JSON keys in environment variable names instead of converting to uppercase"`
+	Config      string   `help:"Manifest file of secrets to export (default: ${default_export_config} in the current directory if exists and --name is not given)" short:"c" type:"path"`
+	Format      string   `help:"Output format (sh, bash, zsh, fish, powershell, cmd, dotenv, json, yaml, docker-env, systemd, kubernetes)" enum:"sh,bash,zsh,fish,powershell,cmd,dotenv,json,yaml,docker-env,systemd,kubernetes" default:"sh"`
+	Output      string   `help:"Write the output to the file (mode 0600) atomically instead of stdout" short:"o" type:"path"`
+	Commands    []string `arg:"" help:"Command to run with exported secrets in environment variables" optional:""`
+
+	RunOptions   `embed:""`
+	WatchOptions `embed:""`
+	FilesOptions `embed:""`
+	Kubernetes   KubernetesOptions `embed:"" prefix:"k8s-" group:"Kubernetes format"`
+}
+
+// Policies of ExportSecrets when secrets set the same environment variable.
+const (
+	// OnConflictError fails with an error naming both sources (default).
+	OnConflictError = "error"
+	// OnConflictFirst keeps the value set first.
+	OnConflictFirst = "first"
+	// OnConflictLast overwrites with the value set last.
+	OnConflictLast = "last"
+)
+
+type exportOptions struct {
+	onConflict  string
+	concurrency int
+	api         APIOptions
 }
 
-func ExportEnvs(ctx context.Context, vaultID string, names []string) (map[string]string, error) {
-	client, err := newSMClient()
+// ExportOption configures ExportEnvs and ExportSecrets.
+type ExportOption func(*exportOptions)
+
+// WithOnConflict sets the policy when secrets set the same environment variable.
+// Keys of an expanded secret are processed in sorted order.
+func WithOnConflict(policy string) ExportOption {
+	return func(o *exportOptions) {
+		o.onConflict = policy
+	}
+}
+
+// WithAPIOptions sets the options of API requests. The default is DefaultAPIOptions.
+// The deadline is not applied; set it to ctx instead.
+func WithAPIOptions(api APIOptions) ExportOption {
+	return func(o *exportOptions) {
+		o.api = api
+	}
+}
+
+// WithConcurrency sets the number of secrets to fetch concurrently. The default is DefaultExportConcurrency.
+func WithConcurrency(n int) ExportOption {
+	return func(o *exportOptions) {
+		o.concurrency = n
+	}
+}
+
+// ExportEnvs returns environment variables of the secrets specified by names like 'name:version:json:prefix'.
+func ExportEnvs(ctx context.Context, vaultID string, names []string, opts ...ExportOption) (map[string]string, error) {
+	secrets, err := ParseExportNames(names)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create SecretManager client: %w", err)
+		return nil, err
 	}
-	secOp := sm.NewSecretOp(client, vaultID)
-	envs := make(map[string]string)
-	for _, np := range names {
-		name, version, isJSON, prefix, err := parseNameParam(np)
-		if err != nil {
+	return ExportSecrets(ctx, vaultID, secrets, opts...)
+}
+
+// ExportSecrets returns environment variables of the secrets.
+// vaultID is used for secrets without their own vault ID.
+func ExportSecrets(ctx context.Context, vaultID string, secrets []ExportSecret, opts ...ExportOption) (map[string]string, error) {
+	o := exportOptions{onConflict: OnConflictError, concurrency: DefaultExportConcurrency, api: DefaultAPIOptions}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	switch o.onConflict {
+	case OnConflictError, OnConflictFirst, OnConflictLast:
+	default:
+		return nil, fmt.Errorf("invalid conflict policy %q", o.onConflict)
+	}
+	if o.concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be positive, got %d", o.concurrency)
+	}
+	keys := make([]unveilKey, len(secrets))
+	for i, s := range secrets {
+		if err := s.Validate(); err != nil {
 			return nil, err
 		}
-		res, err := secOp.Unveil(ctx, v1.Unveil{
-			Name:    name,
-			Version: v1.NewOptNilInt(version),
-		})
+		keys[i] = unveilKey{VaultID: cmp.Or(s.VaultID, vaultID), Name: s.Name, Version: s.Version}
+	}
+	client, err := newSMClient(o.api)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SecretManager client: %w", err)
+	}
+	results := unveilSecrets(ctx, client, keys, o.concurrency)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// report all failed secrets in the order of secrets
+	var errs []error
+	reported := make(map[unveilKey]bool)
+	for i, s := range secrets {
+		res := results[keys[i]]
+		if res.err == nil || reported[keys[i]] || (s.Optional && apiclient.IsNotFoundError(res.err)) {
+			continue
+		}
+		reported[keys[i]] = true
+		errs = append(errs, fmt.Errorf("%s: %w", s.source(keys[i].VaultID, ""), res.err))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	envs := &envSet{
+		onConflict: o.onConflict,
+		values:     make(map[string]string),
+		sources:    make(map[string]string),
+	}
+	for i, s := range secrets {
+		res := results[keys[i]]
+		if res.err != nil {
+			// optional and not found
+			continue
+		}
+		format := s.expandFormat()
+		if format == "" {
+			if err := envs.set(s.envKey(s.Name), res.value, s.source(keys[i].VaultID, "")); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		m, err := expandValue(res.value, format, s.Separator, s.Arrays)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get secret: %w", err)
+			return nil, fmt.Errorf("failed to expand secret %s: %w", s.Name, err)
 		}
-		if isJSON {
-			var m map[string]string
-			if err := json.Unmarshal([]byte(res.Value), &m); err != nil {
-				return nil, fmt.Errorf("failed to parse secret value as JSON object: %w", err)
+		if s.Key != "" {
+			v, ok := m[s.Key]
+			if !ok {
+				return nil, fmt.Errorf("key %s is not found in secret %s", s.Key, s.Name)
 			}
-			for k, v := range m {
-				envs[makeExportEnvKey(k, prefix)] = v
+			if err := envs.set(s.envKey(s.Key), v, s.source(keys[i].VaultID, s.Key)); err != nil {
+				return nil, err
 			}
 			continue
-		} else {
-			envs[makeExportEnvKey(name, prefix)] = res.Value
+		}
+		for k := range s.Rename {
+			if _, ok := m[k]; !ok {
+				return nil, fmt.Errorf("key %s to rename is not found in secret %s", k, s.Name)
+			}
+		}
+		for _, k := range slices.Sorted(maps.Keys(m)) {
+			if err := envs.set(s.envKey(k), m[k], s.source(keys[i].VaultID, k)); err != nil {
+				return nil, err
+			}
 		}
 	}
-	return envs, nil
+	return envs.values, nil
+}
+
+// envSet is a set of environment variables with their sources to detect conflicts.
+type envSet struct {
+	onConflict string
+	values     map[string]string
+	sources    map[string]string
+}
+
+func (e *envSet) set(key, value, source string) error {
+	if prev, ok := e.sources[key]; ok && prev != source {
+		switch e.onConflict {
+		case OnConflictFirst:
+			return nil
+		case OnConflictError:
+			return fmt.Errorf("environment variable %s is set by both %s and %s", key, prev, source)
+		}
+	}
+	e.values[key] = value
+	e.sources[key] = source
+	return nil
+}
+
+// shadowedEnvs returns the sorted keys of envs that already exist in environ.
+func shadowedEnvs(environ []string, envs map[string]string) []string {
+	var shadowed []string
+	for _, env := range environ {
+		k, _, _ := strings.Cut(env, "=")
+		if _, ok := envs[k]; ok {
+			shadowed = append(shadowed, k)
+		}
+	}
+	slices.Sort(shadowed)
+	return slices.Compact(shadowed)
 }
 
 var EnvKeyInvalidRegex = regexp.MustCompile(`[^a-zA-Z0-9_]`)
@@ -65,70 +223,91 @@ func makeExportEnvKey(name, prefix string) string {
 
 func runExportCommand(ctx context.Context, cli *CLI) error {
 	cmd := cli.Secret.Export
-	envMap, err := ExportEnvs(ctx, cli.Secret.VaultID, cmd.Name)
+	secrets, err := cmd.exportSecrets()
+	if err != nil {
+		return err
+	}
+	if err := cmd.FilesOptions.validate(cmd.Commands); err != nil {
+		return err
+	}
+	if cmd.WatchInterval > 0 {
+		return runExportWatch(ctx, cli, secrets)
+	}
+	apiCtx, cancel := cli.APIOptions.withDeadline(ctx)
+	defer cancel()
+	envMap, err := ExportSecrets(apiCtx, cli.Secret.VaultID, secrets, cmd.exportOptions(cli.APIOptions)...)
 	if err != nil {
 		return err
 	}
+	if cmd.Output != "" {
+		var buf bytes.Buffer
+		if err := cmd.write(&buf, envMap); err != nil {
+			return err
+		}
+		if err := atomicfile.WriteFile(cmd.Output, buf.Bytes(), 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", cmd.Output, err)
+		}
+	}
+	if len(cmd.Commands) > 0 && cmd.Files {
+		return runCommandWithSecretFiles(ctx, cmd.FilesOptions, envMap, cmd.Commands)
+	}
 	if len(cmd.Commands) > 0 {
-		envs := make([]string, 0, len(envMap))
-		for k, v := range envMap {
-			envs = append(envs, fmt.Sprintf("%s=%s", k, v))
+		if shadowed := shadowedEnvs(os.Environ(), envMap); len(shadowed) > 0 {
+			slog.Warn("exported secrets override existing environment variables", "names", strings.Join(shadowed, ","))
 		}
-		return runCommandWithEnvs(ctx, cli, envs, cmd.Commands)
+		return runCommandWithEnvs(ctx, cmd.RunOptions, envList(envMap), cmd.Commands)
 	}
-	for k, v := range envMap {
-		fmt.Printf("export %s=%s\n", k, v)
+	if cmd.Output != "" {
+		return nil
 	}
-	return nil
+	return cmd.write(os.Stdout, envMap)
+}
+
+func (cmd *ExportCommand) exportOptions(api APIOptions) []ExportOption {
+	return []ExportOption{WithOnConflict(cmd.OnConflict), WithConcurrency(cmd.Concurrency), WithAPIOptions(api)}
+}
+
+// envList returns envs as KEY=VALUE.
+func envList(envs map[string]string) []string {
+	list := make([]string, 0, len(envs))
+	for k, v := range envs {
+		list = append(list, k+"="+v)
+	}
+	return list
+}
+
+func (cmd *ExportCommand) write(w io.Writer, envs map[string]string) error {
+	if cmd.Format == "kubernetes" {
+		return writeKubernetesManifest(w, cmd.Kubernetes, envs)
+	}
+	return writeExports(w, cmd.Format, envs)
 }
 
-func runCommandWithEnvs(ctx context.Context, cli *CLI, envs []string, command []string) error {
+func runCommandWithEnvs(ctx context.Context, opt RunOptions, envs []string, command []string) error {
 	bin, err := exec.LookPath(command[0])
 	if err != nil {
 		return fmt.Errorf("command is not executable %s: %w", command[0], err)
 	}
-
-	return syscall.Exec(bin, command, append(os.Environ(), envs...))
+	env := mergeEnvs(os.Environ(), envs)
+	if opt.Supervise {
+		return runSupervised(bin, command, env)
+	}
+	return syscall.Exec(bin, command, env)
 }
 
-func parseVersionString(s string) (int, error) {
-	if len(s) == 0 {
-		return 0, nil
-	} else {
-		v, err := strconv.ParseInt(s, 10, 64)
-		if err != nil {
-			return 0, fmt.Errorf("invalid version '%s': %w", s, err)
-		}
-		return int(v), nil
-	}
-}
-
-func parseNameParam(nameParam string) (string, int, bool, string, error) {
-	switch strings.Count(nameParam, ":") {
-	case 0:
-		return nameParam, 0, false, "", nil
-	case 1:
-		parts := strings.SplitN(nameParam, ":", 2)
-		name := parts[0]
-		version, err := parseVersionString(parts[1])
-		return name, version, false, "", err
-	case 2: // for future extension like name:version:json
-		parts := strings.SplitN(nameParam, ":", 3)
-		name := parts[0]
-		version, err := parseVersionString(parts[1])
-		if err != nil {
-			return "", 0, false, "", err
-		}
-		return name, version, parts[2] == "json", "", nil
-	case 3: // for future extension like name:version:json:prefix
-		parts := strings.SplitN(nameParam, ":", 4)
-		name := parts[0]
-		version, err := parseVersionString(parts[1])
-		if err != nil {
-			return "", 0, false, "", err
+// mergeEnvs returns base with envs added. Values in envs override the same keys in base,
+// because a child process may see only the first one of duplicated keys.
+func mergeEnvs(base, envs []string) []string {
+	index := make(map[string]int, len(base))
+	merged := make([]string, 0, len(base)+len(envs))
+	for _, env := range slices.Concat(base, envs) {
+		k, _, _ := strings.Cut(env, "=")
+		if i, ok := index[k]; ok {
+			merged[i] = env
+			continue
 		}
-		return name, version, parts[2] == "json", parts[3], nil
-	default:
-		return "", 0, false, "", fmt.Errorf("invalid name parameter: %s", nameParam)
+		index[k] = len(merged)
+		merged = append(merged, env)
 	}
+	return merged
 }
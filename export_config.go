@@ -0,0 +1,203 @@
+package sscli
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/goccy/go-yaml"
+)
+
+// DefaultExportConfigFile is the manifest file that secret export reads from the current directory
+// if neither --config nor --name is given.
+const DefaultExportConfigFile = "sakura-secrets.yaml"
+
+// ExportConfig is a manifest of secrets to export.
+type ExportConfig struct {
+	Secrets []ExportSecret `yaml:"secrets" json:"secrets"`
+}
+
+// ExportSecret is a secret to export as environment variables.
+type ExportSecret struct {
+	// Name of the secret.
+	Name string `yaml:"name" json:"name"`
+	// Version of the secret. 0 means the latest version.
+	Version int `yaml:"version,omitempty" json:"version,omitempty"`
+	// JSON expands the JSON object value to an environment variable per key. Same as Format "json".
+	JSON bool `yaml:"json,omitempty" json:"json,omitempty"`
+	// Format of the value to expand to an environment variable per key (json, yaml, toml or dotenv).
+	Format string `yaml:"format,omitempty" json:"format,omitempty"`
+	// Separator joins keys of nested objects. Empty means DefaultExpandSeparator.
+	Separator string `yaml:"separator,omitempty" json:"separator,omitempty"`
+	// Arrays is how to expand arrays, ArraysIndex (default) or ArraysJSON.
+	Arrays string `yaml:"arrays,omitempty" json:"arrays,omitempty"`
+	// Prefix is added to the environment variable names.
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+	// Rename maps the secret name (or the JSON key) to the environment variable name, which is used as is.
+	Rename map[string]string `yaml:"rename,omitempty" json:"rename,omitempty"`
+	// Key picks the single key from the expanded value (in JSON unless Format is set).
+	Key string `yaml:"key,omitempty" json:"key,omitempty"`
+	// Env is the environment variable name, which is used as is. It cannot be used with expansion without Key.
+	Env string `yaml:"env,omitempty" json:"env,omitempty"`
+	// KeepCase keeps the case of the secret name (or the JSON key) in the environment variable name.
+	KeepCase bool `yaml:"keep_case,omitempty" json:"keep_case,omitempty"`
+	// Optional skips the secret if it does not exist.
+	Optional bool `yaml:"optional,omitempty" json:"optional,omitempty"`
+	// VaultID overrides the vault of the secret.
+	VaultID string `yaml:"vault_id,omitempty" json:"vault_id,omitempty"`
+}
+
+// Validate validates the secret to export.
+func (s ExportSecret) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if s.Version < 0 {
+		return fmt.Errorf("secret %s: version must not be negative", s.Name)
+	}
+	if s.JSON && s.Format != "" && s.Format != "json" {
+		return fmt.Errorf("secret %s: json cannot be used with format %s", s.Name, s.Format)
+	}
+	if s.Format != "" && !slices.Contains(SecretRefFormats, s.Format) {
+		return fmt.Errorf("secret %s: unknown format %q", s.Name, s.Format)
+	}
+	if s.Arrays != "" && s.Arrays != ArraysIndex && s.Arrays != ArraysJSON {
+		return fmt.Errorf("secret %s: arrays must be %s or %s", s.Name, ArraysIndex, ArraysJSON)
+	}
+	if s.expandFormat() != "" && s.Key == "" && s.Env != "" {
+		return fmt.Errorf("secret %s: env cannot be used with expansion, use prefix or rename instead", s.Name)
+	}
+	if s.Env != "" && EnvKeyInvalidRegex.MatchString(s.Env) {
+		return fmt.Errorf("secret %s: invalid environment variable name %q", s.Name, s.Env)
+	}
+	for k, env := range s.Rename {
+		if env == "" || EnvKeyInvalidRegex.MatchString(env) {
+			return fmt.Errorf("secret %s: invalid environment variable name %q to rename %s", s.Name, env, k)
+		}
+	}
+	return nil
+}
+
+// expandFormat returns the format to expand the value, or "" not to expand.
+func (s ExportSecret) expandFormat() string {
+	switch {
+	case s.Format != "":
+		return s.Format
+	case s.JSON:
+		return "json"
+	case s.Key != "":
+		return "json"
+	default:
+		return ""
+	}
+}
+
+// source describes the secret (and the key) in the vault for messages.
+// It identifies the origin of an environment variable, so secrets of the same name in different vaults differ.
+func (s ExportSecret) source(vaultID, key string) string {
+	src := fmt.Sprintf("secret %q", s.Name)
+	if s.Version > 0 {
+		src += fmt.Sprintf(" version %d", s.Version)
+	}
+	src += fmt.Sprintf(" in vault %q", vaultID)
+	if key != "" {
+		src = fmt.Sprintf("key %q of %s", key, src)
+	}
+	return src
+}
+
+// envKey returns the environment variable name for the secret name or the JSON key.
+func (s ExportSecret) envKey(key string) string {
+	if s.Env != "" {
+		return s.Env
+	}
+	if env, ok := s.Rename[key]; ok {
+		return env
+	}
+	if s.KeepCase {
+		return EnvKeyInvalidRegex.ReplaceAllString(s.Prefix+key, "_")
+	}
+	return makeExportEnvKey(key, s.Prefix)
+}
+
+// LoadExportConfig loads a manifest of secrets to export from a YAML or JSON file.
+func LoadExportConfig(path string) (*ExportConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export config: %w", err)
+	}
+	var cfg ExportConfig
+	if err := yaml.UnmarshalWithOptions(b, &cfg, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf("failed to parse export config %s: %w", path, err)
+	}
+	for i, s := range cfg.Secrets {
+		if err := s.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid export config %s: secrets[%d]: %w", path, i, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// ParseExportNames parses references to secrets like 'name:version:json:prefix' or 'ENV_VAR=name@3#key'
+// into secrets to export. See SecretRef for the syntax.
+func ParseExportNames(names []string) ([]ExportSecret, error) {
+	secrets := make([]ExportSecret, 0, len(names))
+	for _, n := range names {
+		ref, err := ParseSecretRef(n)
+		if err != nil {
+			return nil, err
+		}
+		s := ExportSecret{
+			Name:      ref.Name,
+			Version:   ref.Version,
+			Format:    ref.Format,
+			Separator: ref.Separator,
+			Arrays:    ref.Arrays,
+			Prefix:    ref.Prefix,
+			Key:       ref.Key,
+			Env:       ref.Env,
+		}
+		if err := s.Validate(); err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, s)
+	}
+	return secrets, nil
+}
+
+// exportSecrets returns the secrets to export from --config (or the default manifest) and --name.
+func (cmd *ExportCommand) exportSecrets() ([]ExportSecret, error) {
+	var secrets []ExportSecret
+	path := cmd.Config
+	if path == "" && len(cmd.Name) == 0 {
+		if _, err := os.Stat(DefaultExportConfigFile); err == nil {
+			slog.Info("reading secrets to export from " + DefaultExportConfigFile)
+			path = DefaultExportConfigFile
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if path != "" {
+		cfg, err := LoadExportConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, cfg.Secrets...)
+	}
+	named, err := ParseExportNames(cmd.Name)
+	if err != nil {
+		return nil, err
+	}
+	secrets = append(secrets, named...)
+	if len(secrets) == 0 {
+		return nil, fmt.Errorf("--name or --config is required")
+	}
+	if cmd.KeepCase {
+		for i := range secrets {
+			secrets[i].KeepCase = true
+		}
+	}
+	return secrets, nil
+}
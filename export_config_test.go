@@ -0,0 +1,310 @@
+package sscli
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
+)
+
+const testExportConfig = `
+secrets:
+  - name: db_credentials
+    json: true
+    prefix: DB_
+    rename:
+      password: PGPASSWORD
+  - name: api_key
+    version: 1
+  - name: feature_flags
+    optional: true
+  - name: shared
+    vault_id: other-vault
+`
+
+func TestLoadExportConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultExportConfigFile)
+	if err := os.WriteFile(path, []byte(testExportConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadExportConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ExportSecret{
+		{Name: "db_credentials", JSON: true, Prefix: "DB_", Rename: map[string]string{"password": "PGPASSWORD"}},
+		{Name: "api_key", Version: 1},
+		{Name: "feature_flags", Optional: true},
+		{Name: "shared", VaultID: "other-vault"},
+	}
+	if !reflect.DeepEqual(cfg.Secrets, want) {
+		t.Errorf("got %+v, want %+v", cfg.Secrets, want)
+	}
+}
+
+func TestLoadExportConfigInvalid(t *testing.T) {
+	for _, content := range []string{
+		"secrets:\n  - version: 1\n",
+		"secrets:\n  - name: foo\n    unknown: true\n",
+		"secrets:\n  - name: foo\n    rename:\n      foo: BAD-NAME\n",
+	} {
+		path := filepath.Join(t.TempDir(), DefaultExportConfigFile)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadExportConfig(path); err == nil {
+			t.Errorf("expected error for %q", content)
+		}
+	}
+}
+
+func TestExportSecrets(t *testing.T) {
+	newTestServer(t)
+	createTestSecrets(t, map[string][]string{
+		"db_credentials": {`{"host":"localhost","password":"secret"}`},
+		"api_key":        {"key1", "key2"},
+		"shared":         {"not in other-vault"},
+	})
+	path := filepath.Join(t.TempDir(), DefaultExportConfigFile)
+	if err := os.WriteFile(path, []byte(testExportConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadExportConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// shared exists in testVaultID, but not in other-vault
+	if _, err := ExportSecrets(t.Context(), testVaultID, cfg.Secrets); err == nil {
+		t.Error("expected error for a missing required secret")
+	}
+
+	cfg.Secrets[3].Optional = true
+	envs, err := ExportSecrets(t.Context(), testVaultID, cfg.Secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"DB_HOST":    "localhost",
+		"PGPASSWORD": "secret",
+		"API_KEY":    "key1",
+	}
+	if !maps.Equal(envs, want) {
+		t.Errorf("got %v, want %v", envs, want)
+	}
+}
+
+func TestParseExportNames(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ExportSecret
+		wantErr bool
+	}{
+		{input: "foo", want: ExportSecret{Name: "foo"}},
+		{input: "foo:2::P_", want: ExportSecret{Name: "foo", Version: 2, Prefix: "P_"}},
+		{input: "DB_PASS=prod-db-password", want: ExportSecret{Name: "prod-db-password", Env: "DB_PASS"}},
+		{input: "DB_PASS=prod-db-password:3", want: ExportSecret{Name: "prod-db-password", Version: 3, Env: "DB_PASS"}},
+		{input: "DB_PASS=db_creds#password", want: ExportSecret{Name: "db_creds", Key: "password", Env: "DB_PASS"}},
+		{input: "db_creds:2#password", want: ExportSecret{Name: "db_creds", Version: 2, Key: "password"}},
+		{input: "db_pass=db_creds#password", want: ExportSecret{Name: "db_creds", Key: "password", Env: "db_pass"}},
+		{input: "DB-PASS=foo", wantErr: true},
+		{input: "=foo", wantErr: true},
+		{input: "foo#", wantErr: true},
+		{input: "FOO=foo::json", wantErr: true},
+		{input: "foo::yaml#key", want: ExportSecret{Name: "foo", Format: "yaml", Key: "key"}},
+		{input: "FOO=foo?format=toml&separator=.#a.b", want: ExportSecret{Name: "foo", Format: "toml", Separator: ".", Key: "a.b", Env: "FOO"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseExportNames([]string{tt.input})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got[0], tt.want) {
+				t.Errorf("got %+v, want %+v", got[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestExportSecretsEnvAndKey(t *testing.T) {
+	newTestServer(t)
+	createTestSecrets(t, map[string][]string{
+		"db_creds":         {`{"user":"app","password":"secret"}`},
+		"prod-db-password": {"p1", "p2"},
+		"camelCase":        {`{"apiKey":"key"}`},
+	})
+	secrets, err := ParseExportNames([]string{
+		"DB_PASS=db_creds#password",
+		"OLD_PASS=prod-db-password:1",
+		"prod-db-password",
+		"camelCase::json:my_",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	envs, err := ExportSecrets(t.Context(), testVaultID, secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"DB_PASS":          "secret",
+		"OLD_PASS":         "p1",
+		"PROD_DB_PASSWORD": "p2",
+		"MY_APIKEY":        "key",
+	}
+	if !maps.Equal(envs, want) {
+		t.Errorf("got %v, want %v", envs, want)
+	}
+
+	for i := range secrets {
+		secrets[i].KeepCase = true
+	}
+	envs, err = ExportSecrets(t.Context(), testVaultID, secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]string{
+		"DB_PASS":          "secret",
+		"OLD_PASS":         "p1",
+		"prod_db_password": "p2",
+		"my_apiKey":        "key",
+	}
+	if !maps.Equal(envs, want) {
+		t.Errorf("got %v, want %v", envs, want)
+	}
+
+	if _, err := ExportEnvs(t.Context(), testVaultID, []string{"db_creds#host"}); err == nil {
+		t.Error("expected error for a missing key")
+	}
+}
+
+func TestExportSecretsNested(t *testing.T) {
+	newTestServer(t)
+	createTestSecrets(t, map[string][]string{
+		"app":    {`{"db":{"host":"localhost","port":5432},"debug":false}`},
+		"config": {"db:\n  user: app\n"},
+	})
+	envs, err := ExportEnvs(t.Context(), testVaultID, []string{
+		"app::json:APP_",
+		"PGPORT=app#db_port",
+		"config?format=yaml&separator=__",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"APP_DB_HOST": "localhost",
+		"APP_DB_PORT": "5432",
+		"APP_DEBUG":   "false",
+		"PGPORT":      "5432",
+		"DB__USER":    "app",
+	}
+	if !maps.Equal(envs, want) {
+		t.Errorf("got %v, want %v", envs, want)
+	}
+}
+
+func TestExportSecretsConflict(t *testing.T) {
+	newTestServer(t)
+	createTestSecrets(t, map[string][]string{
+		"db-host": {"from name"},
+		"db":      {`{"db-host":"dash","db_host":"underscore"}`},
+	})
+	names := []string{"db-host", "db::json"}
+
+	_, err := ExportEnvs(t.Context(), testVaultID, names)
+	if err == nil {
+		t.Fatal("expected conflict error")
+	}
+	if want := `environment variable DB_HOST is set by both secret "db-host" in vault "test-vault" and key "db-host" of secret "db" in vault "test-vault"`; err.Error() != want {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if _, err := ExportEnvs(t.Context(), testVaultID, []string{"db::json"}); err == nil {
+		t.Error("expected conflict error for keys in a secret")
+	}
+
+	envs, err := ExportEnvs(t.Context(), testVaultID, names, WithOnConflict(OnConflictFirst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envs["DB_HOST"] != "from name" {
+		t.Errorf("first: got %q", envs["DB_HOST"])
+	}
+	envs, err = ExportEnvs(t.Context(), testVaultID, names, WithOnConflict(OnConflictLast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// keys of a secret are processed in sorted order
+	if envs["DB_HOST"] != "underscore" {
+		t.Errorf("last: got %q", envs["DB_HOST"])
+	}
+
+	// the same secret twice is not a conflict
+	if _, err := ExportEnvs(t.Context(), testVaultID, []string{"db-host", "db-host"}); err != nil {
+		t.Error(err)
+	}
+	sameVault := []ExportSecret{{Name: "db-host"}, {Name: "db-host", VaultID: testVaultID}}
+	if _, err := ExportSecrets(t.Context(), testVaultID, sameVault); err != nil {
+		t.Error(err)
+	}
+
+	// secrets of the same name in different vaults conflict
+	client, err := newSMClient(DefaultAPIOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.SecretmanagerVaultsSecretsCreate(t.Context(), &v1.WrappedCreateSecret{
+		Secret: v1.CreateSecret{Name: "db-host", Value: "from other vault"},
+	}, v1.SecretmanagerVaultsSecretsCreateParams{VaultResourceID: "other-vault"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherVault := []ExportSecret{{Name: "db-host"}, {Name: "db-host", VaultID: "other-vault"}}
+	_, err = ExportSecrets(t.Context(), testVaultID, otherVault)
+	if want := `environment variable DB_HOST is set by both secret "db-host" in vault "test-vault" and secret "db-host" in vault "other-vault"`; err == nil || err.Error() != want {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestShadowedEnvs(t *testing.T) {
+	got := shadowedEnvs([]string{"HOME=/root", "PATH=/bin", "FOO=1"}, map[string]string{"FOO": "x", "BAR": "y", "PATH": "z"})
+	if want := []string{"FOO", "PATH"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestExportCommandDefaultConfig(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile(DefaultExportConfigFile, []byte(testExportConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the manifest in the current directory is read without --name and --config
+	secrets, err := (&ExportCommand{}).exportSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 4 {
+		t.Errorf("unexpected secrets: %+v", secrets)
+	}
+
+	// and is not merged with --name
+	secrets, err = (&ExportCommand{Name: []string{"foo"}}).exportSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 || secrets[0].Name != "foo" {
+		t.Errorf("unexpected secrets: %+v", secrets)
+	}
+}
@@ -0,0 +1,64 @@
+package sscli
+
+import (
+	"context"
+	"sync"
+
+	sm "github.com/sacloud/secretmanager-api-go"
+	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
+)
+
+// DefaultExportConcurrency is the default number of secrets to fetch concurrently in ExportSecrets.
+const DefaultExportConcurrency = 8
+
+// unveilKey identifies a version of a secret to unveil.
+type unveilKey struct {
+	VaultID string
+	Name    string
+	// Version 0 means the latest version.
+	Version int
+}
+
+type unveilResult struct {
+	value string
+	err   error
+}
+
+// unveilSecrets unveils the secrets with up to concurrency requests at a time. Duplicated keys are unveiled once.
+// When ctx is done, it stops sending requests and returns the results so far.
+func unveilSecrets(ctx context.Context, client *v1.Client, keys []unveilKey, concurrency int) map[unveilKey]unveilResult {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[unveilKey]unveilResult, len(keys))
+		seen    = make(map[unveilKey]bool, len(keys))
+		sem     = make(chan struct{}, concurrency)
+	)
+loop:
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break loop
+		}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			var r unveilResult
+			res, err := unveilSecret(ctx, sm.NewSecretOp(client, key.VaultID), key.Name, key.Version)
+			if err != nil {
+				r.err = err
+			} else {
+				r.value = res.Value
+			}
+			mu.Lock()
+			results[key] = r
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	return results
+}
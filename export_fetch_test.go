@@ -0,0 +1,82 @@
+package sscli
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestExportSecretsDedupe(t *testing.T) {
+	var count atomic.Int64
+	newTestServer(t, func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/secrets/unveil") {
+				count.Add(1)
+			}
+			h.ServeHTTP(w, r)
+		})
+	})
+	createTestSecrets(t, map[string][]string{
+		"db": {`{"host":"localhost","password":"secret"}`},
+	})
+	secrets := []ExportSecret{
+		{Name: "db", Key: "host"},
+		{Name: "db", Key: "password", Env: "PGPASSWORD"},
+		{Name: "db", Version: 1, Key: "host", Env: "DB_HOST_V1"},
+	}
+	envs, err := ExportSecrets(t.Context(), testVaultID, secrets, WithConcurrency(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"HOST": "localhost", "PGPASSWORD": "secret", "DB_HOST_V1": "localhost"}
+	if !maps.Equal(envs, want) {
+		t.Errorf("got %v, want %v", envs, want)
+	}
+	// db (latest) and db version 1
+	if n := count.Load(); n != 2 {
+		t.Errorf("unexpected number of unveil requests: %d", n)
+	}
+}
+
+func TestExportSecretsAllErrors(t *testing.T) {
+	newTestServer(t)
+	createTestSecrets(t, map[string][]string{"api_key": {"key1"}})
+	secrets := []ExportSecret{
+		{Name: "missing1"},
+		{Name: "api_key"},
+		{Name: "missing2", Version: 3},
+		{Name: "missing1", Env: "OTHER"},
+		{Name: "missing3", Optional: true},
+	}
+	_, err := ExportSecrets(t.Context(), testVaultID, secrets)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("unexpected errors: %v", err)
+	}
+	if !strings.HasPrefix(lines[0], `secret "missing1" in vault "test-vault": `) || !strings.HasPrefix(lines[1], `secret "missing2" version 3 in vault "test-vault": `) {
+		t.Errorf("unexpected errors: %v", err)
+	}
+}
+
+func TestExportSecretsCanceled(t *testing.T) {
+	newTestServer(t)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err := ExportSecrets(ctx, testVaultID, []ExportSecret{{Name: "a"}, {Name: "b"}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestExportSecretsInvalidConcurrency(t *testing.T) {
+	if _, err := ExportSecrets(t.Context(), testVaultID, []ExportSecret{{Name: "a"}}, WithConcurrency(0)); err == nil {
+		t.Error("expected error for concurrency 0")
+	}
+}
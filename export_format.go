@@ -0,0 +1,150 @@
+package sscli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// exportFormatters format an environment variable as a line of each format.
+// Keys are made by makeExportEnvKey, so they never need quoting.
+var exportFormatters = map[string]func(key, value string) (string, error){
+	"sh":         formatShExport,
+	"bash":       formatShExport,
+	"zsh":        formatShExport,
+	"fish":       formatFishExport,
+	"powershell": formatPowerShellExport,
+	"cmd":        formatCmdExport,
+	"dotenv":     formatDotenv,
+	"docker-env": formatDockerEnv,
+	"systemd":    formatSystemdEnv,
+}
+
+// writeExports writes envs in the format, sorted by key.
+func writeExports(w io.Writer, format string, envs map[string]string) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(envs, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal envs as JSON: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case "yaml":
+		b, err := yaml.Marshal(envs)
+		if err != nil {
+			return fmt.Errorf("failed to marshal envs as YAML: %w", err)
+		}
+		_, err = w.Write(b)
+		return err
+	}
+	f, ok := exportFormatters[format]
+	if !ok {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+	for _, k := range slices.Sorted(maps.Keys(envs)) {
+		line, err := f(k, envs[k])
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatShExport formats for POSIX sh, bash and zsh.
+// Nothing is special in single quotes except a single quote itself,
+// which is written as a closing quote, an escaped quote and an opening quote.
+func formatShExport(key, value string) (string, error) {
+	return fmt.Sprintf("export %s='%s'", key, strings.ReplaceAll(value, `'`, `'\''`)), nil
+}
+
+var fishQuoteReplacer = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// formatFishExport formats for fish. Only backslashes and single quotes are escaped in single quotes.
+func formatFishExport(key, value string) (string, error) {
+	return fmt.Sprintf("set -gx %s '%s';", key, fishQuoteReplacer.Replace(value)), nil
+}
+
+// PowerShell also treats typographic single quotes as single quotes, so all of them are doubled.
+var powerShellQuoteReplacer = strings.NewReplacer(
+	"'", "''",
+	"\u2018", "\u2018\u2018",
+	"\u2019", "\u2019\u2019",
+	"\u201a", "\u201a\u201a",
+	"\u201b", "\u201b\u201b",
+)
+
+// formatPowerShellExport formats for PowerShell. Nothing is expanded in single quoted strings.
+func formatPowerShellExport(key, value string) (string, error) {
+	return fmt.Sprintf("$env:%s = '%s'", key, powerShellQuoteReplacer.Replace(value)), nil
+}
+
+var cmdEscapeReplacer = strings.NewReplacer(
+	"^", "^^",
+	"&", "^&",
+	"|", "^|",
+	"<", "^<",
+	">", "^>",
+	"(", "^(",
+	")", "^)",
+	`"`, `^"`,
+	"%", "%%",
+)
+
+// formatCmdExport formats for cmd.exe batch files.
+// Special characters are escaped with carets and percent signs are doubled.
+// Newlines cannot be expressed, so values containing them are rejected.
+func formatCmdExport(key, value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", fmt.Errorf("value of %s contains a newline, which cannot be exported in cmd format", key)
+	}
+	return fmt.Sprintf("set %s=%s", key, cmdEscapeReplacer.Replace(value)), nil
+}
+
+var dotenvQuoteReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"$", `\$`,
+	"\n", `\n`,
+	"\r", `\r`,
+)
+
+// formatDotenv formats for .env files read by docker compose and dotenv libraries.
+// Values are single quoted to be taken literally if possible,
+// otherwise double quoted with escapes.
+func formatDotenv(key, value string) (string, error) {
+	if !strings.ContainsAny(value, "'\r\n") {
+		return fmt.Sprintf("%s='%s'", key, value), nil
+	}
+	return fmt.Sprintf(`%s="%s"`, key, dotenvQuoteReplacer.Replace(value)), nil
+}
+
+// formatDockerEnv formats for `docker run --env-file`, which takes values literally without quoting.
+// Newlines cannot be expressed, so values containing them are rejected.
+func formatDockerEnv(key, value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", fmt.Errorf("value of %s contains a newline, which cannot be exported in docker-env format", key)
+	}
+	return fmt.Sprintf("%s=%s", key, value), nil
+}
+
+var systemdQuoteReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"`", "\\`",
+	"$", `\$`,
+)
+
+// formatSystemdEnv formats for systemd EnvironmentFile=.
+// In double quotes, newlines are kept as is and backslash, double quote, backquote and dollar are escaped.
+func formatSystemdEnv(key, value string) (string, error) {
+	return fmt.Sprintf(`%s="%s"`, key, systemdQuoteReplacer.Replace(value)), nil
+}
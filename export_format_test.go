@@ -0,0 +1,121 @@
+package sscli
+
+import (
+	"bytes"
+	"maps"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+func TestWriteExports(t *testing.T) {
+	envs := map[string]string{
+		"FOO": "it's $HOME `id` \\n\nnext",
+		"BAR": `a"b & c|d %PATH% (x)`,
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "sh",
+			want:   "export BAR='a\"b & c|d %PATH% (x)'\nexport FOO='it'\\''s $HOME `id` \\n\nnext'\n",
+		},
+		{
+			format: "fish",
+			want:   "set -gx BAR 'a\"b & c|d %PATH% (x)';\nset -gx FOO 'it\\'s $HOME `id` \\\\n\nnext';\n",
+		},
+		{
+			format: "powershell",
+			want:   "$env:BAR = 'a\"b & c|d %PATH% (x)'\n$env:FOO = 'it''s $HOME `id` \\n\nnext'\n",
+		},
+		{
+			format: "dotenv",
+			want:   "BAR='a\"b & c|d %PATH% (x)'\nFOO=\"it's \\$HOME `id` \\\\n\\nnext\"\n",
+		},
+		{
+			format: "systemd",
+			want:   "BAR=\"a\\\"b & c|d %PATH% (x)\"\nFOO=\"it's \\$HOME \\`id\\` \\\\n\nnext\"\n",
+		},
+		{
+			format: "json",
+			want:   "{\n  \"BAR\": \"a\\\"b \\u0026 c|d %PATH% (x)\",\n  \"FOO\": \"it's $HOME `id` \\\\n\\nnext\"\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeExports(&buf, tt.format, envs); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteExportsCmd(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeExports(&buf, "cmd", map[string]string{"BAR": `a"b & c|d %PATH% (x) ^`}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "set BAR=a^\"b ^& c^|d %%PATH%% ^(x^) ^^\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := writeExports(&buf, "cmd", map[string]string{"FOO": "a\nb"}); err == nil {
+		t.Error("expected error for a value with a newline")
+	}
+}
+
+func TestWriteExportsDockerEnv(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeExports(&buf, "docker-env", map[string]string{"FOO": `'a' "b" $c`, "BAR": ""}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "BAR=\nFOO='a' \"b\" $c\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := writeExports(&buf, "docker-env", map[string]string{"FOO": "a\nb"}); err == nil {
+		t.Error("expected error for a value with a newline")
+	}
+}
+
+func TestWriteExportsYAML(t *testing.T) {
+	envs := map[string]string{"FOO": "a: b\nnext", "BAR": "123", "BAZ": "true"}
+	var buf bytes.Buffer
+	if err := writeExports(&buf, "yaml", envs); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	if err := yaml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(got, envs) {
+		t.Errorf("got %v, want %v", got, envs)
+	}
+	if !strings.HasPrefix(buf.String(), "BAR:") {
+		t.Errorf("keys are not sorted: %q", buf.String())
+	}
+}
+
+func TestWriteExportsShEval(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+	value := "it's $HOME `id` $(id) \\n \"quoted\"\nnext line; exit 1"
+	var buf bytes.Buffer
+	if err := writeExports(&buf, "sh", map[string]string{"FOO": value}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(sh, "-c", buf.String()+`printf '%s' "$FOO"`).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(out); got != value {
+		t.Errorf("got %q, want %q", got, value)
+	}
+}
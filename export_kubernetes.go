@@ -0,0 +1,105 @@
+package sscli
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"maps"
+
+	"github.com/goccy/go-yaml"
+)
+
+// KubernetesOptions are options for the kubernetes format of the export command.
+type KubernetesOptions struct {
+	Name          string            `help:"Name of the Secret (required for the kubernetes format)"`
+	Namespace     string            `help:"Namespace of the Secret"`
+	Label         map[string]string `help:"Labels of the Secret (and ConfigMap) like 'app=myapp' (repeatable)"`
+	StringData    bool              `help:"Write values as plain text in stringData instead of base64 in data"`
+	ConfigMapKey  []string          `help:"Keys to write to a ConfigMap instead of the Secret, for non-sensitive values (repeatable)" name:"config-map-key" placeholder:"KEY"`
+	ConfigMapName string            `help:"Name of the ConfigMap (default: same as the Secret)" name:"config-map-name"`
+}
+
+type kubernetesManifest struct {
+	APIVersion string             `yaml:"apiVersion"`
+	Kind       string             `yaml:"kind"`
+	Metadata   kubernetesMetadata `yaml:"metadata"`
+	Type       string             `yaml:"type,omitempty"`
+	Data       map[string]string  `yaml:"data,omitempty"`
+	StringData map[string]string  `yaml:"stringData,omitempty"`
+}
+
+type kubernetesMetadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+// writeKubernetesManifest writes envs as a v1/Secret manifest.
+// Keys in opt.ConfigMapKey are written to a v1/ConfigMap manifest in the same stream instead.
+func writeKubernetesManifest(w io.Writer, opt KubernetesOptions, envs map[string]string) error {
+	if opt.Name == "" {
+		return fmt.Errorf("--k8s-name is required for the kubernetes format")
+	}
+	secrets := maps.Clone(envs)
+	configs := make(map[string]string, len(opt.ConfigMapKey))
+	for _, k := range opt.ConfigMapKey {
+		v, ok := secrets[k]
+		if !ok {
+			return fmt.Errorf("config map key %s is not exported", k)
+		}
+		configs[k] = v
+		delete(secrets, k)
+	}
+
+	secret := kubernetesManifest{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: kubernetesMetadata{
+			Name:      opt.Name,
+			Namespace: opt.Namespace,
+			Labels:    opt.Label,
+		},
+		Type: "Opaque",
+	}
+	if opt.StringData {
+		secret.StringData = secrets
+	} else {
+		secret.Data = make(map[string]string, len(secrets))
+		for k, v := range secrets {
+			secret.Data[k] = base64.StdEncoding.EncodeToString([]byte(v))
+		}
+	}
+	manifests := []kubernetesManifest{secret}
+	if len(configs) > 0 {
+		name := opt.ConfigMapName
+		if name == "" {
+			name = opt.Name
+		}
+		manifests = append(manifests, kubernetesManifest{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Metadata: kubernetesMetadata{
+				Name:      name,
+				Namespace: opt.Namespace,
+				Labels:    opt.Label,
+			},
+			Data: configs,
+		})
+	}
+
+	for i, m := range manifests {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w, "---"); err != nil {
+				return err
+			}
+		}
+		b, err := yaml.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s manifest: %w", m.Kind, err)
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package sscli
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteKubernetesManifest(t *testing.T) {
+	envs := map[string]string{
+		"DB_PASSWORD": "p@ss: word",
+		"DB_HOST":     "db.example.com",
+		"API_KEY":     "key",
+	}
+	tests := []struct {
+		name string
+		opt  KubernetesOptions
+		want string
+	}{
+		{
+			name: "data",
+			opt:  KubernetesOptions{Name: "myapp", Namespace: "prod", Label: map[string]string{"app": "myapp"}},
+			want: `apiVersion: v1
+kind: Secret
+metadata:
+  name: myapp
+  namespace: prod
+  labels:
+    app: myapp
+type: Opaque
+data:
+  API_KEY: a2V5
+  DB_HOST: ZGIuZXhhbXBsZS5jb20=
+  DB_PASSWORD: cEBzczogd29yZA==
+`,
+		},
+		{
+			name: "stringData with ConfigMap",
+			opt:  KubernetesOptions{Name: "myapp", StringData: true, ConfigMapKey: []string{"DB_HOST"}},
+			want: `apiVersion: v1
+kind: Secret
+metadata:
+  name: myapp
+type: Opaque
+stringData:
+  API_KEY: key
+  DB_PASSWORD: "p@ss: word"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: myapp
+data:
+  DB_HOST: db.example.com
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeKubernetesManifest(&buf, tt.opt, envs); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteKubernetesManifestErrors(t *testing.T) {
+	var buf bytes.Buffer
+	envs := map[string]string{"FOO": "foo"}
+	if err := writeKubernetesManifest(&buf, KubernetesOptions{}, envs); err == nil {
+		t.Error("expected error without name")
+	}
+	if err := writeKubernetesManifest(&buf, KubernetesOptions{Name: "x", ConfigMapKey: []string{"BAR"}}, envs); err == nil {
+		t.Error("expected error for a config map key not exported")
+	}
+}
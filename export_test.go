@@ -4,7 +4,8 @@ import (
 	"testing"
 )
 
-func TestParseNameParam(t *testing.T) {
+// TestParseSecretRefColonSyntax tests the backward compatibility with the colon separated syntax.
+func TestParseSecretRefColonSyntax(t *testing.T) {
 	tests := []struct {
 		name       string
 		input      string
@@ -71,12 +72,9 @@ func TestParseNameParam(t *testing.T) {
 			wantPrefix: "MYAPP_",
 		},
 		{
-			name:       "non-json third part",
-			input:      "foo:1:notjson",
-			wantName:   "foo",
-			wantVer:    1,
-			wantJSON:   false,
-			wantPrefix: "",
+			name:    "non-json third part",
+			input:   "foo:1:notjson",
+			wantErr: true,
 		},
 		{
 			name:    "invalid version",
@@ -97,28 +95,28 @@ func TestParseNameParam(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			name, ver, isJSON, prefix, err := parseNameParam(tt.input)
+			ref, err := ParseSecretRef(tt.input)
 			if tt.wantErr {
 				if err == nil {
-					t.Errorf("parseNameParam(%q) expected error, got nil", tt.input)
+					t.Errorf("ParseSecretRef(%q) expected error, got nil", tt.input)
 				}
 				return
 			}
 			if err != nil {
-				t.Errorf("parseNameParam(%q) unexpected error: %v", tt.input, err)
+				t.Errorf("ParseSecretRef(%q) unexpected error: %v", tt.input, err)
 				return
 			}
-			if name != tt.wantName {
-				t.Errorf("parseNameParam(%q) name = %q, want %q", tt.input, name, tt.wantName)
+			if ref.Name != tt.wantName {
+				t.Errorf("ParseSecretRef(%q) name = %q, want %q", tt.input, ref.Name, tt.wantName)
 			}
-			if ver != tt.wantVer {
-				t.Errorf("parseNameParam(%q) version = %d, want %d", tt.input, ver, tt.wantVer)
+			if ref.Version != tt.wantVer {
+				t.Errorf("ParseSecretRef(%q) version = %d, want %d", tt.input, ref.Version, tt.wantVer)
 			}
-			if isJSON != tt.wantJSON {
-				t.Errorf("parseNameParam(%q) isJSON = %v, want %v", tt.input, isJSON, tt.wantJSON)
+			if isJSON := ref.Format == "json"; isJSON != tt.wantJSON {
+				t.Errorf("ParseSecretRef(%q) isJSON = %v, want %v", tt.input, isJSON, tt.wantJSON)
 			}
-			if prefix != tt.wantPrefix {
-				t.Errorf("parseNameParam(%q) prefix = %q, want %q", tt.input, prefix, tt.wantPrefix)
+			if ref.Prefix != tt.wantPrefix {
+				t.Errorf("ParseSecretRef(%q) prefix = %q, want %q", tt.input, ref.Prefix, tt.wantPrefix)
 			}
 		})
 	}
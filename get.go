@@ -16,7 +16,9 @@ type GetCommand struct {
 
 func runGetCommand(ctx context.Context, cli *CLI) error {
 	cmd := cli.Secret.Get
-	client, err := newSMClient()
+	ctx, cancel := cli.APIOptions.withDeadline(ctx)
+	defer cancel()
+	client, err := newSMClient(cli.APIOptions)
 	if err != nil {
 		return fmt.Errorf("failed to create SecretManager client: %w", err)
 	}
@@ -24,8 +26,7 @@ func runGetCommand(ctx context.Context, cli *CLI) error {
 	var name string
 	var version int
 	if cmd.SecretVersion == 0 {
-		var err error
-		name, version, _, _, err = parseNameParam(cmd.Name)
+		name, version, err = parseGetName(cmd.Name)
 		if err != nil {
 			return err
 		}
@@ -35,12 +36,9 @@ func runGetCommand(ctx context.Context, cli *CLI) error {
 	}
 
 	secOp := sm.NewSecretOp(client, cli.Secret.VaultID)
-	res, err := secOp.Unveil(ctx, v1.Unveil{
-		Name:    name,
-		Version: v1.NewOptNilInt(version),
-	})
+	res, err := unveilSecret(ctx, secOp, name, version)
 	if err != nil {
-		return fmt.Errorf("failed to get secret: %w", err)
+		return err
 	}
 	if cmd.ValueOnly {
 		fmt.Println(res.Value)
@@ -49,3 +47,28 @@ func runGetCommand(ctx context.Context, cli *CLI) error {
 	}
 	return nil
 }
+
+// parseGetName parses the name argument like 'name', 'name:3' or 'name@3'.
+// Options to export secrets are errors, not to ignore them silently.
+func parseGetName(s string) (string, int, error) {
+	ref, err := ParseSecretRef(s)
+	if err != nil {
+		return "", 0, err
+	}
+	if ref.Env != "" || ref.Key != "" || ref.Format != "" || ref.Prefix != "" || ref.Separator != "" || ref.Arrays != "" {
+		return "", 0, fmt.Errorf("secret get accepts only a name and a version, got %q (escape =:@?#\\ with a backslash to use them in a name)", s)
+	}
+	return ref.Name, ref.Version, nil
+}
+
+// unveilSecret gets the secret of the version. version 0 means the latest version.
+func unveilSecret(ctx context.Context, secOp sm.SecretAPI, name string, version int) (*v1.Unveil, error) {
+	res, err := secOp.Unveil(ctx, v1.Unveil{
+		Name:    name,
+		Version: v1.NewOptNilInt(version),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret: %w", err)
+	}
+	return res, nil
+}
@@ -3,8 +3,12 @@ module github.com/fujiwara/sakura-secrets-cli
 go 1.25.5
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/Songmu/prompter v0.5.1
 	github.com/alecthomas/kong v1.13.0
+	github.com/getkin/kin-openapi v0.133.0
+	github.com/goccy/go-yaml v1.19.2
+	github.com/sacloud/api-client-go v0.3.4
 	github.com/sacloud/saclient-go v0.2.6
 	github.com/sacloud/secretmanager-api-go v0.3.1
 	golang.org/x/sys v0.40.0
@@ -18,18 +22,26 @@ require (
 	github.com/go-faster/errors v0.7.1 // indirect
 	github.com/go-faster/jx v1.1.0 // indirect
 	github.com/go-faster/yaml v0.4.6 // indirect
+	github.com/go-openapi/jsonpointer v0.21.0 // indirect
+	github.com/go-openapi/swag v0.23.0 // indirect
 	github.com/gofrs/flock v0.13.0 // indirect
 	github.com/golang-jwt/jwt/v5 v5.3.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/hashicorp/go-cleanhttp v0.5.2 // indirect
 	github.com/hashicorp/go-retryablehttp v0.7.8 // indirect
+	github.com/josharian/intern v1.0.0 // indirect
+	github.com/mailru/easyjson v0.7.7 // indirect
 	github.com/mattn/go-colorable v0.1.14 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
+	github.com/oasdiff/yaml v0.0.0-20250309154309-f31be36b4037 // indirect
+	github.com/oasdiff/yaml3 v0.0.0-20250309153720-d2182401db90 // indirect
 	github.com/ogen-go/ogen v1.14.0 // indirect
-	github.com/sacloud/api-client-go v0.3.4 // indirect
+	github.com/perimeterx/marshmallow v1.1.5 // indirect
 	github.com/sacloud/go-http v0.1.9 // indirect
 	github.com/sacloud/packages-go v0.0.12 // indirect
 	github.com/segmentio/asm v1.2.0 // indirect
+	github.com/woodsbury/decimal128 v1.3.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/ratelimit v0.3.1 // indirect
 	go.uber.org/zap v1.27.0 // indirect
@@ -39,4 +51,5 @@ require (
 	golang.org/x/term v0.32.0 // indirect
 	golang.org/x/text v0.25.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,180 @@
+package sscli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	sm "github.com/sacloud/secretmanager-api-go"
+	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
+
+	"github.com/fujiwara/sakura-secrets-cli/internal/atomicfile"
+)
+
+type InjectCommand struct {
+	VaultID  string   `help:"Vault ID for references without a vault like sakura:///name" env:"VAULT_ID"`
+	File     []string `help:"Files to resolve references in, like 'template' (written to stdout) or 'template=destination'" short:"f" placeholder:"SRC[=DEST]"`
+	Commands []string `arg:"" help:"Command to run with references in environment variables resolved" optional:""`
+
+	RunOptions `embed:""`
+}
+
+// secretResolver resolves references to secrets. Each secret is fetched only once.
+type secretResolver struct {
+	ctx            context.Context
+	client         *v1.Client
+	defaultVaultID string
+	cache          map[SecretURI]string
+}
+
+func newSecretResolver(ctx context.Context, api APIOptions, defaultVaultID string) (*secretResolver, error) {
+	client, err := newSMClient(api)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SecretManager client: %w", err)
+	}
+	return &secretResolver{
+		ctx:            ctx,
+		client:         client,
+		defaultVaultID: defaultVaultID,
+		cache:          make(map[SecretURI]string),
+	}, nil
+}
+
+// resolve returns the value that the reference points to.
+func (r *secretResolver) resolve(ref *SecretURI) (string, error) {
+	vaultID := ref.VaultID
+	if vaultID == "" {
+		vaultID = r.defaultVaultID
+	}
+	if vaultID == "" {
+		return "", fmt.Errorf("vault ID is required to resolve %s", ref)
+	}
+	key := SecretURI{VaultID: vaultID, Name: ref.Name, Version: ref.Version}
+	value, ok := r.cache[key]
+	if !ok {
+		res, err := unveilSecret(r.ctx, sm.NewSecretOp(r.client, vaultID), ref.Name, ref.Version)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", ref, err)
+		}
+		value = res.Value
+		r.cache[key] = value
+	}
+	if ref.JSONKey == "" {
+		return value, nil
+	}
+	v, ok, err := jsonObjectValue(ref.Name, value, ref.JSONKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", ref, err)
+	}
+	if !ok {
+		return "", fmt.Errorf("failed to resolve %s: key %s is not found", ref, ref.JSONKey)
+	}
+	return v, nil
+}
+
+// resolveText replaces all references in text with their values.
+func (r *secretResolver) resolveText(text string) (string, error) {
+	var resolveErr error
+	resolved := SecretURIRegex.ReplaceAllStringFunc(text, func(s string) string {
+		if resolveErr != nil {
+			return s
+		}
+		if literal, ok := strings.CutPrefix(s, secretURIEscape); ok {
+			return literal
+		}
+		ref, err := ParseSecretURI(s)
+		if err != nil {
+			resolveErr = err
+			return s
+		}
+		v, err := r.resolve(ref)
+		if err != nil {
+			resolveErr = err
+			return s
+		}
+		return v
+	})
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+	return resolved, nil
+}
+
+// resolveEnvs returns environment variables in environ whose values are references, with the values resolved.
+// Values of escaped references are returned without the escape.
+func (r *secretResolver) resolveEnvs(environ []string) ([]string, error) {
+	var envs []string
+	for _, env := range environ {
+		k, v, _ := strings.Cut(env, "=")
+		if literal, ok := strings.CutPrefix(v, secretURIEscape+SecretURIScheme+"://"); ok {
+			envs = append(envs, k+"="+SecretURIScheme+"://"+literal)
+			continue
+		}
+		if !strings.HasPrefix(v, SecretURIScheme+"://") {
+			continue
+		}
+		ref, err := ParseSecretURI(v)
+		if err != nil {
+			return nil, fmt.Errorf("environment variable %s: %w", k, err)
+		}
+		resolved, err := r.resolve(ref)
+		if err != nil {
+			return nil, fmt.Errorf("environment variable %s: %w", k, err)
+		}
+		envs = append(envs, k+"="+resolved)
+	}
+	return envs, nil
+}
+
+func runInjectCommand(ctx context.Context, cli *CLI) error {
+	cmd := cli.Inject
+	if len(cmd.File) == 0 && len(cmd.Commands) == 0 {
+		return fmt.Errorf("--file or a command to run is required")
+	}
+	apiCtx, cancel := cli.APIOptions.withDeadline(ctx)
+	defer cancel()
+	r, err := newSecretResolver(apiCtx, cli.APIOptions, cmd.VaultID)
+	if err != nil {
+		return err
+	}
+
+	// resolve all files before writing any files, not to leave partial results on errors
+	type injected struct {
+		dest string
+		body string
+	}
+	results := make([]injected, 0, len(cmd.File))
+	for _, f := range cmd.File {
+		src, dest, _ := strings.Cut(f, "=")
+		b, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		body, err := r.resolveText(string(b))
+		if err != nil {
+			return fmt.Errorf("failed to inject secrets into %s: %w", src, err)
+		}
+		results = append(results, injected{dest: dest, body: body})
+	}
+	for _, res := range results {
+		if res.dest == "" {
+			if _, err := os.Stdout.WriteString(res.body); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := atomicfile.WriteFile(res.dest, []byte(res.body), 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", res.dest, err)
+		}
+	}
+
+	if len(cmd.Commands) == 0 {
+		return nil
+	}
+	envs, err := r.resolveEnvs(os.Environ())
+	if err != nil {
+		return err
+	}
+	return runCommandWithEnvs(ctx, cmd.RunOptions, envs, cmd.Commands)
+}
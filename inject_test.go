@@ -0,0 +1,92 @@
+package sscli
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSecretResolver(t *testing.T) {
+	newTestServer(t)
+	createTestSecrets(t, map[string][]string{
+		"password": {"v1", "v2"},
+		"db":       {`{"host":"localhost","port":5432}`},
+	})
+	r, err := newSecretResolver(t.Context(), DefaultAPIOptions, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := `password: "sakura://test-vault/password"
+old_password: 'sakura://test-vault/password?version=1'
+db: host=sakura://test-vault/db#json.host port=sakura://test-vault/db#json.port
+`
+	want := `password: "v2"
+old_password: 'v1'
+db: host=localhost port=5432
+`
+	got, err := r.resolveText(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// trailing punctuation is not a part of references, and escaped references are written literally
+	text = `See sakura://test-vault/password. Or (sakura://test-vault/db#json.host), \sakura://test-vault/password?version=1.`
+	want = `See v2. Or (localhost), sakura://test-vault/password?version=1.`
+	if got, err := r.resolveText(text); err != nil {
+		t.Fatal(err)
+	} else if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, text := range []string{
+		"sakura://test-vault/nothing",
+		"sakura://test-vault/db#json.user",
+		"sakura://test-vault/password#json.key",
+		"sakura:///password",
+		"sakura://test-vault/password?version=x",
+	} {
+		if _, err := r.resolveText(text); err == nil {
+			t.Errorf("expected error for %s", text)
+		}
+	}
+
+	envs, err := r.resolveEnvs([]string{
+		"HOME=/root",
+		"PASSWORD=sakura://test-vault/password",
+		"DB_HOST=sakura://test-vault/db#json.host",
+		"NOTE=see sakura://test-vault/password",
+		`LITERAL=\sakura://test-vault/password`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"PASSWORD=v2", "DB_HOST=localhost", "LITERAL=sakura://test-vault/password"}; !slices.Equal(envs, want) {
+		t.Errorf("got %v, want %v", envs, want)
+	}
+}
+
+func TestSecretResolverDefaultVault(t *testing.T) {
+	newTestServer(t)
+	createTestSecrets(t, map[string][]string{"password": {"v1"}})
+	r, err := newSecretResolver(t.Context(), DefaultAPIOptions, testVaultID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.resolveText("sakura:///password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "v1" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestMergeEnvs(t *testing.T) {
+	got := mergeEnvs([]string{"A=1", "B=2", "C=3"}, []string{"B=x", "D=4"})
+	if want := []string{"A=1", "B=x", "C=3", "D=4"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
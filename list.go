@@ -5,18 +5,19 @@ import (
 	"encoding/json"
 	"fmt"
 
-	sm "github.com/sacloud/secretmanager-api-go"
+	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
 )
 
 type ListCommand struct{}
 
 func runListCommand(ctx context.Context, cli *CLI) error {
-	client, err := newSMClient()
+	ctx, cancel := cli.APIOptions.withDeadline(ctx)
+	defer cancel()
+	client, err := newSMClient(cli.APIOptions)
 	if err != nil {
 		return fmt.Errorf("failed to create SecretManager client: %w", err)
 	}
-	secOp := sm.NewSecretOp(client, cli.Secret.VaultID)
-	res, err := secOp.List(ctx)
+	res, err := listSecrets(ctx, client, cli.Secret.VaultID)
 	if err != nil {
 		return fmt.Errorf("failed to list secrets: %w", err)
 	}
@@ -26,6 +27,24 @@ func runListCommand(ctx context.Context, cli *CLI) error {
 	return nil
 }
 
+// listSecrets returns all secrets in the vault, following pages.
+func listSecrets(ctx context.Context, client *v1.Client, vaultID string) ([]v1.Secret, error) {
+	var secrets []v1.Secret
+	for {
+		res, err := client.SecretmanagerVaultsSecretsList(withPageFrom(ctx, len(secrets)), v1.SecretmanagerVaultsSecretsListParams{
+			VaultResourceID: vaultID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, res.Secrets...)
+		total, ok := res.Total.Get()
+		if !ok || len(res.Secrets) == 0 || len(secrets) >= total {
+			return secrets, nil
+		}
+	}
+}
+
 func jsonString(v interface{}) string {
 	b, err := json.Marshal(v)
 	if err != nil {
@@ -0,0 +1,102 @@
+package sscli
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
+
+	"github.com/fujiwara/sakura-secrets-cli/localserver"
+)
+
+const testPrefix = "/api/cloud/1.1"
+
+// newTestServer starts a localserver and points the SecretManager client at it.
+// middlewares wrap the localserver in order, to observe or alter requests in tests.
+func newTestServer(t *testing.T, middlewares ...func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+	return newTestServerWith(t, nil, middlewares...)
+}
+
+// newTestServerWith is newTestServer with the options of the localserver.
+func newTestServerWith(t *testing.T, opts []localserver.Option, middlewares ...func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+	var h http.Handler = localserver.NewServer(testPrefix, opts...)
+	for _, m := range middlewares {
+		h = m(h)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	t.Setenv("SAKURA_API_ROOT_URL", srv.URL+testPrefix)
+	t.Setenv("SAKURA_ACCESS_TOKEN", "dummy")
+	t.Setenv("SAKURA_ACCESS_TOKEN_SECRET", "dummy")
+	return srv
+}
+
+// captureStdout returns the lines written to os.Stdout by fn.
+func captureStdout(t *testing.T, fn func() error) []string {
+	t.Helper()
+	f, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stdout := os.Stdout
+	os.Stdout = f
+	err = fn()
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for s := bufio.NewScanner(f); s.Scan(); {
+		lines = append(lines, s.Text())
+	}
+	return lines
+}
+
+func TestListAcrossPages(t *testing.T) {
+	newTestServerWith(t, []localserver.Option{localserver.WithMaxPageSize(2)})
+	ctx := t.Context()
+	for i := range 5 {
+		createTestSecrets(t, map[string][]string{fmt.Sprintf("secret%d", i): {"value"}})
+	}
+	client, err := newSMClient(DefaultAPIOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		_, err := client.SecretmanagerVaultsCreate(ctx, &v1.WrappedCreateVault{
+			Vault: v1.CreateVault{Name: fmt.Sprintf("vault%d", i), KmsKeyID: "kms"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cli := &CLI{APIOptions: DefaultAPIOptions}
+	cli.Secret.VaultID = testVaultID
+	lines := captureStdout(t, func() error { return runListCommand(ctx, cli) })
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 secrets, got %d: %v", len(lines), lines)
+	}
+	for i, line := range lines {
+		if want := fmt.Sprintf(`{"Name":"secret%d","LatestVersion":1}`, i); line != want {
+			t.Errorf("secret %d: expected %s, got %s", i, want, line)
+		}
+	}
+
+	// the vault of the secrets above is created implicitly
+	lines = captureStdout(t, func() error { return runVaultListCommand(ctx, cli) })
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 vaults, got %d: %v", len(lines), lines)
+	}
+}
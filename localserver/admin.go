@@ -0,0 +1,150 @@
+package localserver
+
+import (
+	"net/http"
+)
+
+// AdminPrefix is the path prefix of the admin API enabled by WithAdmin.
+// It is not under the API prefix, so it never collides with API routes.
+const AdminPrefix = "/_localserver"
+
+// DumpVault is a vault with all secret versions in plain text, returned by the dump admin API.
+type DumpVault struct {
+	VaultMeta
+	Secrets []DumpSecret `json:"Secrets"`
+}
+
+// DumpSecret is a secret with all versions in plain text.
+type DumpSecret struct {
+	Name          string         `json:"Name"`
+	LatestVersion int            `json:"LatestVersion"`
+	Versions      map[int]string `json:"Versions"`
+}
+
+func (s *Server) registerAdminRoutes() {
+	s.handleAdmin("POST "+AdminPrefix+"/reset", s.handleReset)
+	s.handleAdmin("GET "+AdminPrefix+"/snapshot", s.handleSnapshot)
+	s.handleAdmin("POST "+AdminPrefix+"/restore", s.handleRestore)
+	s.handleAdmin("GET "+AdminPrefix+"/dump", s.handleDump)
+	s.handleAdmin("GET "+AdminPrefix+"/requests", s.handleListRequests)
+	s.handleAdmin("DELETE "+AdminPrefix+"/requests", s.handleClearRequests)
+	s.handleAdmin("GET "+AdminPrefix+"/faults", s.handleGetFaults)
+	s.handleAdmin("PUT "+AdminPrefix+"/faults", s.handleSetFaults)
+	s.handleAdmin("DELETE "+AdminPrefix+"/faults", s.handleClearFaults)
+}
+
+// handleAdmin registers an admin API handler.
+// The admin API accesses all vaults, so it requires credentials not scoped to vaults, if credentials are configured.
+func (s *Server) handleAdmin(pattern string, h http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if len(s.credentials) > 0 {
+			cred := s.lookupCredential(r)
+			if cred == nil {
+				writeUnauthorized(w)
+				return
+			}
+			if cred.scoped() {
+				writeJSON(w, http.StatusForbidden, map[string]string{
+					"error": "credentials scoped to vaults cannot use the admin API",
+				})
+				return
+			}
+		}
+		h(w, r)
+	})
+}
+
+func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
+	if err := s.store.Restore(&Snapshot{}); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.store.Snapshot())
+}
+
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	var snap Snapshot
+	if err := readJSON(r, &snap); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.store.Restore(&snap); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Dump returns all vaults with all secret versions in plain text.
+func (s *Server) Dump() ([]DumpVault, error) {
+	vaults := s.store.ListVaults()
+	result := make([]DumpVault, 0, len(vaults))
+	for _, v := range vaults {
+		secrets, err := s.store.List(v.ID)
+		if err != nil {
+			return nil, err
+		}
+		dv := DumpVault{VaultMeta: v, Secrets: make([]DumpSecret, 0, len(secrets))}
+		for _, sec := range secrets {
+			ds := DumpSecret{
+				Name:          sec.Name,
+				LatestVersion: sec.LatestVersion,
+				Versions:      make(map[int]string, sec.LatestVersion),
+			}
+			for ver := 1; ver <= sec.LatestVersion; ver++ {
+				value, _, err := s.store.Unveil(v.ID, sec.Name, ver)
+				if err != nil {
+					return nil, err
+				}
+				ds.Versions[ver] = value
+			}
+			dv.Secrets = append(dv.Secrets, ds)
+		}
+		result = append(result, dv)
+	}
+	return result, nil
+}
+
+func (s *Server) handleDump(w http.ResponseWriter, r *http.Request) {
+	dump, err := s.Dump()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dump)
+}
+
+func (s *Server) handleListRequests(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.RequestLog())
+}
+
+func (s *Server) handleClearRequests(w http.ResponseWriter, r *http.Request) {
+	s.log.clear()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleGetFaults(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Faults())
+}
+
+func (s *Server) handleSetFaults(w http.ResponseWriter, r *http.Request) {
+	var c FaultConfig
+	if err := readJSON(r, &c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.SetFaults(c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Faults())
+}
+
+func (s *Server) handleClearFaults(w http.ResponseWriter, r *http.Request) {
+	s.SetFaults(FaultConfig{})
+	w.WriteHeader(http.StatusNoContent)
+}
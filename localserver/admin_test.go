@@ -0,0 +1,147 @@
+package localserver_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
+
+	"github.com/fujiwara/sakura-secrets-cli/localserver"
+)
+
+func adminRequest(t *testing.T, method, url string, body []byte, v any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		io.Copy(io.Discard, resp.Body)
+	}
+	return resp.StatusCode
+}
+
+func TestAdminSnapshotRestore(t *testing.T) {
+	srv := httptest.NewServer(localserver.NewServer(testPrefix, localserver.WithAdmin()))
+	defer srv.Close()
+	ctx := t.Context()
+	adminURL := srv.URL + localserver.AdminPrefix
+	secOp := newTestSecretOp(t, srv.URL, testVaultID)
+
+	for _, v := range []string{"v1", "v2"} {
+		if _, err := secOp.Create(ctx, v1.CreateSecret{Name: "foo", Value: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Snapshot
+	req, _ := http.NewRequest(http.MethodGet, adminURL+"/snapshot", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+	if bytes.Contains(snapshot, []byte(`"v1"`)) {
+		t.Fatal("snapshot contains a plain text value")
+	}
+
+	// Reset
+	if status := adminRequest(t, http.MethodPost, adminURL+"/reset", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", status)
+	}
+	secrets, err := secOp.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 0 {
+		t.Fatalf("expected 0 secrets after reset, got %d", len(secrets))
+	}
+
+	// Restore
+	if status := adminRequest(t, http.MethodPost, adminURL+"/restore", snapshot, nil); status != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", status)
+	}
+	unveiled, err := secOp.Unveil(ctx, v1.Unveil{Name: "foo", Version: v1.NewOptNilInt(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unveiled.Value != "v1" {
+		t.Fatalf("unexpected unveil response after restore: %+v", unveiled)
+	}
+
+	// Dump
+	var dump []localserver.DumpVault
+	if status := adminRequest(t, http.MethodGet, adminURL+"/dump", nil, &dump); status != http.StatusOK {
+		t.Fatalf("unexpected status: %d", status)
+	}
+	if len(dump) != 1 || dump[0].ID != testVaultID || len(dump[0].Secrets) != 1 {
+		t.Fatalf("unexpected dump: %+v", dump)
+	}
+	if vers := dump[0].Secrets[0].Versions; vers[1] != "v1" || vers[2] != "v2" {
+		t.Fatalf("unexpected dump versions: %+v", vers)
+	}
+
+	// Restoring a snapshot of another server (different master key) fails
+	other := localserver.NewServer(testPrefix, localserver.WithAdmin())
+	rec := httptest.NewRecorder()
+	other.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, localserver.AdminPrefix+"/restore", bytes.NewReader(snapshot)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for snapshot with another master key, got %d", rec.Code)
+	}
+}
+
+func TestAdminRequestLog(t *testing.T) {
+	srv := httptest.NewServer(localserver.NewServer(testPrefix, localserver.WithAdmin()))
+	defer srv.Close()
+	ctx := t.Context()
+	adminURL := srv.URL + localserver.AdminPrefix
+	secOp := newTestSecretOp(t, srv.URL, testVaultID)
+
+	if _, err := secOp.Create(ctx, v1.CreateSecret{Name: "foo", Value: "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := secOp.Unveil(ctx, v1.Unveil{Name: "nonexistent"}); err == nil {
+		t.Fatal("expected error")
+	}
+
+	var entries []localserver.RequestLogEntry
+	if status := adminRequest(t, http.MethodGet, adminURL+"/requests", nil, &entries); status != http.StatusOK {
+		t.Fatalf("unexpected status: %d", status)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d: %+v", len(entries), entries)
+	}
+	if e := entries[0]; e.Operation != "secretmanager_vaults_secrets_create" || e.Status != http.StatusCreated || e.VaultID != testVaultID {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if e := entries[1]; e.Operation != "secretmanager_vaults_secrets_unveil" || e.Status != http.StatusNotFound {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+
+	if status := adminRequest(t, http.MethodDelete, adminURL+"/requests", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", status)
+	}
+	if status := adminRequest(t, http.MethodGet, adminURL+"/requests", nil, &entries); status != http.StatusOK || len(entries) != 0 {
+		t.Fatalf("unexpected request log after clear: %d %+v", status, entries)
+	}
+}
@@ -0,0 +1,120 @@
+package localserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Credential is an API key accepted by the server with HTTP basic auth.
+type Credential struct {
+	AccessToken       string `yaml:"access_token"`
+	AccessTokenSecret string `yaml:"access_token_secret"`
+	// Vaults limits the vaults accessible with this credential.
+	// Empty means all vaults. A scoped credential cannot create vaults.
+	Vaults []string `yaml:"vaults"`
+}
+
+func (c *Credential) allows(vaultID string) bool {
+	return len(c.Vaults) == 0 || slices.Contains(c.Vaults, vaultID)
+}
+
+func (c *Credential) scoped() bool {
+	return len(c.Vaults) > 0
+}
+
+// credentialsFile is the format of a credentials file.
+//
+//	credentials:
+//	  - access_token: key1
+//	    access_token_secret: secret1
+//	  - access_token: key2
+//	    access_token_secret: secret2
+//	    vaults: [vault-a]
+type credentialsFile struct {
+	Credentials []Credential `yaml:"credentials"`
+}
+
+// LoadCredentials loads credentials from a YAML or JSON file.
+func LoadCredentials(path string) ([]Credential, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	var f credentialsFile
+	if err := yaml.UnmarshalWithOptions(b, &f, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file %s: %w", path, err)
+	}
+	if len(f.Credentials) == 0 {
+		return nil, fmt.Errorf("no credentials in %s", path)
+	}
+	for i, c := range f.Credentials {
+		if c.AccessToken == "" || c.AccessTokenSecret == "" {
+			return nil, fmt.Errorf("credentials[%d]: access_token and access_token_secret are required", i)
+		}
+	}
+	return f.Credentials, nil
+}
+
+type credentialKey struct{}
+
+// credentialFrom returns the authenticated credential of the request, or nil if authentication is disabled.
+func credentialFrom(ctx context.Context) *Credential {
+	c, _ := ctx.Value(credentialKey{}).(*Credential)
+	return c
+}
+
+// authenticate returns a handler that requires HTTP basic auth with one of the credentials.
+// If no credentials are configured, all requests are accepted.
+func (s *Server) authenticate(operation string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(s.credentials) == 0 {
+			h(w, r)
+			return
+		}
+		cred := s.lookupCredential(r)
+		if cred == nil {
+			writeUnauthorized(w)
+			return
+		}
+		if vaultID := r.PathValue("vault_id"); vaultID != "" && !cred.allows(vaultID) {
+			writeJSON(w, http.StatusForbidden, map[string]string{
+				"error": fmt.Sprintf("access to vault %q is not allowed", vaultID),
+			})
+			return
+		}
+		if operation == "secretmanager_vaults_create" && cred.scoped() {
+			writeJSON(w, http.StatusForbidden, map[string]string{
+				"error": "credentials scoped to vaults cannot create vaults",
+			})
+			return
+		}
+		h(w, r.WithContext(context.WithValue(r.Context(), credentialKey{}, cred)))
+	}
+}
+
+func writeUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="sakura-secrets-localserver"`)
+	writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid credentials"})
+}
+
+func (s *Server) lookupCredential(r *http.Request) *Credential {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return nil
+	}
+	for i := range s.credentials {
+		c := &s.credentials[i]
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(c.AccessToken)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(c.AccessTokenSecret)) == 1
+		if userOK && passOK {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package localserver_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	sm "github.com/sacloud/secretmanager-api-go"
+	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
+
+	"github.com/fujiwara/sakura-secrets-cli/localserver"
+)
+
+func TestAuth(t *testing.T) {
+	srv := httptest.NewServer(localserver.NewServer(testPrefix, localserver.WithCredentials(
+		localserver.Credential{AccessToken: "admin", AccessTokenSecret: "admin-secret"},
+		localserver.Credential{AccessToken: "app", AccessTokenSecret: "app-secret", Vaults: []string{"vault-a"}},
+	)))
+	defer srv.Close()
+	ctx := t.Context()
+
+	// No Authorization header
+	resp, err := http.Get(srv.URL + testPrefix + "/secretmanager/vaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unexpected status without credentials: %d", resp.StatusCode)
+	}
+
+	// Wrong credentials
+	if _, err := sm.NewSecretOp(newTestClient(t, srv.URL, "admin", "wrong"), "vault-a").List(ctx); err == nil {
+		t.Error("expected error with wrong credentials")
+	}
+
+	// Unscoped credentials
+	adminClient := newTestClient(t, srv.URL, "admin", "admin-secret")
+	for _, vaultID := range []string{"vault-a", "vault-b"} {
+		if _, err := sm.NewSecretOp(adminClient, vaultID).Create(ctx, v1.CreateSecret{Name: "foo", Value: "bar"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := sm.NewVaultOp(adminClient).Create(ctx, v1.CreateVault{Name: "v", KmsKeyID: "kms-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Scoped credentials
+	appClient := newTestClient(t, srv.URL, "app", "app-secret")
+	if _, err := sm.NewSecretOp(appClient, "vault-a").Unveil(ctx, v1.Unveil{Name: "foo"}); err != nil {
+		t.Errorf("unexpected error for allowed vault: %v", err)
+	}
+	if _, err := sm.NewSecretOp(appClient, "vault-b").Unveil(ctx, v1.Unveil{Name: "foo"}); err == nil {
+		t.Error("expected error for vault out of scope")
+	}
+	if _, err := sm.NewVaultOp(appClient).Create(ctx, v1.CreateVault{Name: "v", KmsKeyID: "kms-1"}); err == nil {
+		t.Error("expected error for creating vault with scoped credentials")
+	}
+	vaults, err := sm.NewVaultOp(appClient).List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vaults) != 1 || vaults[0].ID != "vault-a" {
+		t.Errorf("unexpected vaults for scoped credentials: %+v", vaults)
+	}
+}
+
+func TestLoadCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.yaml")
+	writeTestFile(t, path, `
+credentials:
+  - access_token: key1
+    access_token_secret: secret1
+  - access_token: key2
+    access_token_secret: secret2
+    vaults: [vault-a]
+`)
+	creds, err := localserver.LoadCredentials(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creds) != 2 || creds[1].AccessToken != "key2" || len(creds[1].Vaults) != 1 {
+		t.Errorf("unexpected credentials: %+v", creds)
+	}
+
+	writeTestFile(t, path, "credentials:\n  - access_token: key1\n")
+	if _, err := localserver.LoadCredentials(path); err == nil {
+		t.Error("expected error for missing access_token_secret")
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	srv := httptest.NewServer(localserver.NewServer(testPrefix, localserver.WithAdmin(), localserver.WithCredentials(
+		localserver.Credential{AccessToken: "admin", AccessTokenSecret: "admin-secret"},
+		localserver.Credential{AccessToken: "app", AccessTokenSecret: "app-secret", Vaults: []string{"vault-a"}},
+	)))
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		user   string
+		pass   string
+		status int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"wrong credentials", "admin", "wrong", http.StatusUnauthorized},
+		{"scoped credentials", "app", "app-secret", http.StatusForbidden},
+		{"unscoped credentials", "admin", "admin-secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+localserver.AdminPrefix+"/dump", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.pass)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("unexpected status: %d", resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package localserver
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// MasterKeySize is the size of a master key in bytes (AES-256).
+const MasterKeySize = 32
+
+// ErrDecrypt is returned (wrapped) when a ciphertext cannot be authenticated,
+// e.g. it was tampered with or encrypted with a different master key.
+var ErrDecrypt = errors.New("failed to decrypt")
+
+// GenerateMasterKey returns a new random master key.
+func GenerateMasterKey() ([]byte, error) {
+	key := make([]byte, MasterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate master key: %w", err)
+	}
+	return key, nil
+}
+
+// ParseMasterKey decodes a base64 encoded master key.
+func ParseMasterKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace([]byte(s))))
+	if err != nil {
+		return nil, fmt.Errorf("master key must be base64 encoded: %w", err)
+	}
+	if len(key) != MasterKeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d bytes", MasterKeySize, len(key))
+	}
+	return key, nil
+}
+
+// LoadMasterKeyFile reads a master key from a file.
+// The file may contain the raw key or the base64 encoded key.
+func LoadMasterKeyFile(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key file: %w", err)
+	}
+	if len(b) == MasterKeySize {
+		return b, nil
+	}
+	return ParseMasterKey(string(b))
+}
+
+// EncodeMasterKey returns the base64 encoded form of key, as accepted by ParseMasterKey.
+func EncodeMasterKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with AES-GCM and returns nonce || ciphertext.
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open decrypts nonce || ciphertext produced by seal.
+func open(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// keyAAD binds a wrapped data key to its vault ID and secret name,
+// so data keys cannot be swapped between secrets, even of the same name in other vaults.
+func keyAAD(vaultID, name string) []byte {
+	return []byte("key\x00" + vaultID + "\x00" + name)
+}
+
+// valueAAD binds an encrypted value to its secret name and version,
+// so ciphertexts cannot be swapped between secrets or versions.
+func valueAAD(name string, version int) []byte {
+	return []byte("value\x00" + name + "\x00" + strconv.Itoa(version))
+}
+
+// keyCheckPlaintext is encrypted with the master key to detect a wrong master key on load.
+var keyCheckPlaintext = []byte("sakura-secrets-localserver")
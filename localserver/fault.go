@@ -0,0 +1,214 @@
+package localserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Duration is a time.Duration encoded as a string like "100ms" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"100ms\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// FaultConfig configures latency and fault injection.
+type FaultConfig struct {
+	// Latency is added to every API response.
+	Latency Duration `json:"Latency"`
+	// Jitter adds a random latency between 0 and Jitter.
+	Jitter Duration `json:"Jitter"`
+	// Rules are evaluated in order. The first rule that fires decides the response.
+	Rules []FaultRule `json:"Rules"`
+}
+
+// FaultRule injects an error response or a dropped connection.
+type FaultRule struct {
+	// Operation is an operationId in openapi.json (e.g. "secretmanager_vaults_secrets_unveil").
+	// Empty or "*" matches all operations.
+	Operation string `json:"Operation"`
+	// Rate is the probability of firing, between 0 and 1. It defaults to 1 when omitted in JSON.
+	Rate float64 `json:"Rate"`
+	// Status is the HTTP status code to respond with (e.g. 429, 500, 503).
+	Status int `json:"Status"`
+	// RetryAfter is the value of the Retry-After header in seconds. 0 means no header.
+	RetryAfter int `json:"RetryAfter"`
+	// Drop closes the connection without responding, instead of responding with Status.
+	Drop bool `json:"Drop"`
+}
+
+// UnmarshalJSON decodes the rule, with Rate defaulting to 1 as ParseFaultRule.
+func (r *FaultRule) UnmarshalJSON(b []byte) error {
+	type rule FaultRule
+	v := rule{Rate: 1}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*r = FaultRule(v)
+	return nil
+}
+
+// Validate checks the configuration.
+func (c FaultConfig) Validate() error {
+	if c.Latency < 0 || c.Jitter < 0 {
+		return errors.New("latency and jitter must not be negative")
+	}
+	for i, r := range c.Rules {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("rules[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the rule.
+func (r FaultRule) Validate() error {
+	if r.Rate < 0 || r.Rate > 1 {
+		return fmt.Errorf("rate must be between 0 and 1, got %v", r.Rate)
+	}
+	if r.Drop {
+		if r.Status != 0 {
+			return errors.New("status and drop are mutually exclusive")
+		}
+	} else if r.Status < 400 || r.Status > 599 {
+		return fmt.Errorf("status must be between 400 and 599, got %d", r.Status)
+	}
+	if r.RetryAfter < 0 {
+		return errors.New("retry-after must not be negative")
+	}
+	return nil
+}
+
+func (r FaultRule) matches(operation string) bool {
+	return r.Operation == "" || r.Operation == "*" || r.Operation == operation
+}
+
+// ParseFaultRule parses a rule from a comma separated list of key=value pairs, for command line flags.
+//
+//	status=503,rate=0.1,retry-after=2,op=secretmanager_vaults_secrets_unveil
+//	drop,rate=0.5
+//
+// drop without a value means drop=true.
+// rate defaults to 1 and op defaults to all operations.
+func ParseFaultRule(s string) (FaultRule, error) {
+	r := FaultRule{Rate: 1}
+	for _, kv := range strings.Split(s, ",") {
+		k, v, hasValue := strings.Cut(strings.TrimSpace(kv), "=")
+		var err error
+		switch k {
+		case "op", "operation":
+			r.Operation = v
+		case "rate":
+			r.Rate, err = strconv.ParseFloat(v, 64)
+		case "status":
+			r.Status, err = strconv.Atoi(v)
+		case "retry-after":
+			r.RetryAfter, err = strconv.Atoi(v)
+		case "drop":
+			r.Drop = true
+			if hasValue {
+				r.Drop, err = strconv.ParseBool(v)
+			}
+		default:
+			return FaultRule{}, fmt.Errorf("invalid fault rule %q: unknown key %q", s, k)
+		}
+		if err != nil {
+			return FaultRule{}, fmt.Errorf("invalid fault rule %q: %s: %w", s, k, err)
+		}
+	}
+	if err := r.Validate(); err != nil {
+		return FaultRule{}, fmt.Errorf("invalid fault rule %q: %w", s, err)
+	}
+	return r, nil
+}
+
+// faults holds the current FaultConfig of a Server.
+type faults struct {
+	mu     sync.RWMutex
+	config FaultConfig
+}
+
+func (f *faults) get() FaultConfig {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	c := f.config
+	c.Rules = append([]FaultRule{}, f.config.Rules...)
+	return c
+}
+
+func (f *faults) set(c FaultConfig) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.config = c
+	f.config.Rules = append([]FaultRule{}, c.Rules...)
+	return nil
+}
+
+// wrap returns a handler that applies the configured latency and faults before calling h.
+func (f *faults) wrap(operation string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := f.get()
+		delay := time.Duration(c.Latency)
+		if c.Jitter > 0 {
+			delay += rand.N(time.Duration(c.Jitter))
+		}
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		for _, rule := range c.Rules {
+			if !rule.matches(operation) || rand.Float64() >= rule.Rate {
+				continue
+			}
+			if rule.Drop {
+				dropConnection(w)
+				return
+			}
+			if rule.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(rule.RetryAfter))
+			}
+			writeJSON(w, rule.Status, map[string]string{
+				"error": fmt.Sprintf("injected fault: %s", http.StatusText(rule.Status)),
+			})
+			return
+		}
+		h(w, r)
+	}
+}
+
+func dropConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		// e.g. HTTP/2; abort the response instead
+		log.Printf("failed to hijack connection: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
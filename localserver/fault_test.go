@@ -0,0 +1,203 @@
+package localserver_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fujiwara/sakura-secrets-cli/localserver"
+)
+
+func testUnveilRequest(t *testing.T, serverURL string) (*http.Response, error) {
+	t.Helper()
+	body := `{"Secret":{"Name":"foo"}}`
+	return http.Post(serverURL+testPrefix+"/secretmanager/vaults/"+testVaultID+"/secrets/unveil", "application/json", bytes.NewBufferString(body))
+}
+
+func TestFaultStatus(t *testing.T) {
+	srv := httptest.NewServer(localserver.NewServer(testPrefix, localserver.WithFaults(localserver.FaultConfig{
+		Rules: []localserver.FaultRule{
+			{Operation: "secretmanager_vaults_secrets_list", Rate: 1, Status: 500},
+			{Operation: "secretmanager_vaults_secrets_unveil", Rate: 1, Status: 503, RetryAfter: 3},
+		},
+	})))
+	defer srv.Close()
+
+	resp, err := testUnveilRequest(t, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	if ra := resp.Header.Get("Retry-After"); ra != "3" {
+		t.Errorf("unexpected Retry-After: %q", ra)
+	}
+
+	// Other operations are not affected
+	resp, err = http.Get(srv.URL + testPrefix + "/secretmanager/vaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+}
+
+func TestFaultDrop(t *testing.T) {
+	srv := httptest.NewServer(localserver.NewServer(testPrefix, localserver.WithFaults(localserver.FaultConfig{
+		Rules: []localserver.FaultRule{{Rate: 1, Drop: true}},
+	})))
+	defer srv.Close()
+
+	if resp, err := testUnveilRequest(t, srv.URL); err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected connection error, got status %d", resp.StatusCode)
+	}
+}
+
+func TestFaultLatency(t *testing.T) {
+	srv := httptest.NewServer(localserver.NewServer(testPrefix, localserver.WithFaults(localserver.FaultConfig{
+		Latency: localserver.Duration(200 * time.Millisecond),
+	})))
+	defer srv.Close()
+
+	start := time.Now()
+	resp, err := testUnveilRequest(t, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("response is too fast: %s", elapsed)
+	}
+}
+
+func TestFaultAdmin(t *testing.T) {
+	srv := httptest.NewServer(localserver.NewServer(testPrefix, localserver.WithAdmin()))
+	defer srv.Close()
+	adminURL := srv.URL + localserver.AdminPrefix + "/faults"
+
+	put := func(body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPut, adminURL, bytes.NewBufferString(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := put(`{"Rules":[{"Rate":1,"Status":429,"RetryAfter":1}]}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+	resp, err := testUnveilRequest(t, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(adminURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c localserver.FaultConfig
+	if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(c.Rules) != 1 || c.Rules[0].Status != 429 {
+		t.Errorf("unexpected config: %+v", c)
+	}
+
+	// Rate defaults to 1
+	if resp := put(`{"Rules":[{"Status":503}]}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+	resp, err = testUnveilRequest(t, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 of the rule without Rate, got %d", resp.StatusCode)
+	}
+
+	if resp := put(`{"Rules":[{"Rate":2,"Status":503}]}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid rate, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, adminURL, nil)
+	if resp, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	} else {
+		resp.Body.Close()
+	}
+	resp, err = testUnveilRequest(t, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown secret after clearing faults, got %d", resp.StatusCode)
+	}
+}
+
+func TestAdminDisabledByDefault(t *testing.T) {
+	srv := httptest.NewServer(localserver.NewServer(testPrefix))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + localserver.AdminPrefix + "/faults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+}
+
+func TestParseFaultRule(t *testing.T) {
+	r, err := localserver.ParseFaultRule("status=503,rate=0.5,retry-after=2,op=secretmanager_vaults_secrets_unveil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := localserver.FaultRule{Operation: "secretmanager_vaults_secrets_unveil", Rate: 0.5, Status: 503, RetryAfter: 2}
+	if r != want {
+		t.Errorf("got %+v, want %+v", r, want)
+	}
+	r, err = localserver.ParseFaultRule("drop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Drop || r.Rate != 1 {
+		t.Errorf("unexpected rule: %+v", r)
+	}
+	r, err = localserver.ParseFaultRule("drop=true,rate=0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Drop || r.Rate != 0.5 {
+		t.Errorf("unexpected rule: %+v", r)
+	}
+	r, err = localserver.ParseFaultRule("status=503,drop=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Drop || r.Status != 503 {
+		t.Errorf("unexpected rule: %+v", r)
+	}
+	for _, s := range []string{"status=200", "rate=0.5", "status=503,drop", "drop=yes", "foo=bar", "status=503,rate=x"} {
+		if _, err := localserver.ParseFaultRule(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package localserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fujiwara/sakura-secrets-cli/internal/atomicfile"
+)
+
+// DataFileName is the name of the data file created in a data directory.
+const DataFileName = "localserver.json"
+
+// FileStore is a Store persisted to a JSON file.
+// The whole state is written atomically on every mutation and loaded at creation.
+// Secret values are written encrypted, as they are held in memory,
+// so the same master key is required to load the file again.
+type FileStore struct {
+	*Store
+	path string
+	mu   sync.Mutex // serializes mutation, save and rollback
+}
+
+var _ Backend = (*FileStore)(nil)
+
+// NewFileStore creates a FileStore backed by the file at path, encrypted with masterKey.
+// If the file exists, its contents are loaded.
+func NewFileStore(path string, masterKey []byte) (*FileStore, error) {
+	store, err := NewStoreWithKey(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	fst := &FileStore{
+		Store: store,
+		path:  path,
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fst, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read data file: %w", err)
+	}
+	var st Snapshot
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, fmt.Errorf("failed to parse data file %s: %w", path, err)
+	}
+	if err := fst.Store.Restore(&st); err != nil {
+		return nil, fmt.Errorf("failed to load data file %s: %w", path, err)
+	}
+	return fst, nil
+}
+
+// NewFileStoreInDir creates a FileStore backed by DataFileName in dir.
+// dir is created if it does not exist.
+func NewFileStoreInDir(dir string, masterKey []byte) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	return NewFileStore(filepath.Join(dir, DataFileName), masterKey)
+}
+
+// Path returns the path of the data file.
+func (f *FileStore) Path() string {
+	return f.path
+}
+
+// CreateVault creates a new vault and saves the store.
+func (f *FileStore) CreateVault(meta VaultMeta) (VaultMeta, error) {
+	var m VaultMeta
+	err := f.mutate(func() (err error) {
+		m, err = f.Store.CreateVault(meta)
+		return err
+	})
+	if err != nil {
+		return VaultMeta{}, err
+	}
+	return m, nil
+}
+
+// UpdateVault updates a vault and saves the store.
+func (f *FileStore) UpdateVault(vaultID string, meta VaultMeta) (VaultMeta, error) {
+	var m VaultMeta
+	err := f.mutate(func() (err error) {
+		m, err = f.Store.UpdateVault(vaultID, meta)
+		return err
+	})
+	if err != nil {
+		return VaultMeta{}, err
+	}
+	return m, nil
+}
+
+// DeleteVault removes a vault and saves the store.
+func (f *FileStore) DeleteVault(vaultID string) error {
+	return f.mutate(func() error {
+		return f.Store.DeleteVault(vaultID)
+	})
+}
+
+// Create creates a secret version and saves the store.
+func (f *FileStore) Create(vaultID, name, value string) (int, error) {
+	var v int
+	err := f.mutate(func() (err error) {
+		v, err = f.Store.Create(vaultID, name, value)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v, nil
+}
+
+// Delete removes a secret and saves the store.
+func (f *FileStore) Delete(vaultID, name string) error {
+	return f.mutate(func() error {
+		return f.Store.Delete(vaultID, name)
+	})
+}
+
+// Restore replaces the store contents with snap and saves the store.
+func (f *FileStore) Restore(snap *Snapshot) error {
+	return f.mutate(func() error {
+		return f.Store.Restore(snap)
+	})
+}
+
+// mutate applies fn to the store and saves it.
+// If saving fails, the store is rolled back, so that memory does not diverge from the data file.
+func (f *FileStore) mutate(fn func() error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	prev := f.Store.Snapshot()
+	if err := fn(); err != nil {
+		return err
+	}
+	if err := f.save(); err != nil {
+		if rerr := f.Store.Restore(prev); rerr != nil {
+			return errors.Join(err, fmt.Errorf("failed to roll back: %w", rerr))
+		}
+		return err
+	}
+	return nil
+}
+
+// save writes the store contents to a temporary file and renames it to the data file.
+func (f *FileStore) save() error {
+	b, err := json.MarshalIndent(f.Store.Snapshot(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode data: %w", err)
+	}
+	if err := atomicfile.WriteFile(f.path, b, 0600); err != nil {
+		return fmt.Errorf("failed to save data file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package localserver_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
+
+	"github.com/fujiwara/sakura-secrets-cli/localserver"
+)
+
+func TestFileStorePersistence(t *testing.T) {
+	dir := t.TempDir()
+	ctx := t.Context()
+	key := newTestMasterKey(t)
+
+	fst, err := localserver.NewFileStoreInDir(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(localserver.NewServer(testPrefix, localserver.WithBackend(fst)))
+	secOp := newTestSecretOp(t, srv.URL, testVaultID)
+	for _, value := range []string{"plain-value-1", "plain-value-2"} {
+		if _, err := secOp.Create(ctx, v1.CreateSecret{Name: "foo", Value: value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vault, err := newTestVaultOp(t, srv.URL).Create(ctx, v1.CreateVault{Name: "v", KmsKeyID: "kms-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	path := filepath.Join(dir, localserver.DataFileName)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "plain-value") {
+		t.Fatal("secret value is stored in plain text")
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if fi.Mode().Perm() != 0600 {
+		t.Fatalf("unexpected data file permission: %o", fi.Mode().Perm())
+	}
+
+	// Reload from the same file
+	fst2, err := localserver.NewFileStore(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv2 := httptest.NewServer(localserver.NewServer(testPrefix, localserver.WithBackend(fst2)))
+	defer srv2.Close()
+	secOp2 := newTestSecretOp(t, srv2.URL, testVaultID)
+
+	unveiled, err := secOp2.Unveil(ctx, v1.Unveil{Name: "foo", Version: v1.NewOptNilInt(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unveiled.Value != "plain-value-1" {
+		t.Fatalf("unexpected unveil response: %+v", unveiled)
+	}
+	unveiled, err = secOp2.Unveil(ctx, v1.Unveil{Name: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unveiled.Value != "plain-value-2" || unveiled.Version != v1.NewOptNilInt(2) {
+		t.Fatalf("unexpected unveil response: %+v", unveiled)
+	}
+	got, err := newTestVaultOp(t, srv2.URL).Read(ctx, vault.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "v" || got.KmsKeyID != "kms-1" {
+		t.Fatalf("unexpected vault: %+v", got)
+	}
+
+	// Newly created vaults must not reuse IDs
+	vault2, err := newTestVaultOp(t, srv2.URL).Create(ctx, v1.CreateVault{Name: "v2", KmsKeyID: "kms-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vault2.ID == vault.ID {
+		t.Fatalf("vault ID %s is reused", vault2.ID)
+	}
+}
+
+func newTestMasterKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := localserver.GenerateMasterKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestFileStoreWrongMasterKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	fst, err := localserver.NewFileStore(path, newTestMasterKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fst.Create(testVaultID, "foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := localserver.NewFileStore(path, newTestMasterKey(t)); err == nil {
+		t.Fatal("expected error for wrong master key")
+	}
+}
+
+func TestFileStoreTampered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	key := newTestMasterKey(t)
+	fst, err := localserver.NewFileStore(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"value-1", "value-2"} {
+		if _, err := fst.Create(testVaultID, "foo", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Flip a bit of the version 1 ciphertext and swap the version 2 ciphertext into version 3
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]any
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	sec := data["Vaults"].([]any)[0].(map[string]any)["Secrets"].([]any)[0].(map[string]any)
+	versions := sec["Versions"].(map[string]any)
+	v1Bytes, err := base64.StdEncoding.DecodeString(versions["1"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1Bytes[len(v1Bytes)-1] ^= 0x01
+	versions["1"] = base64.StdEncoding.EncodeToString(v1Bytes)
+	versions["3"] = versions["2"]
+	sec["LatestVersion"] = 3
+	b, err = json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fst2, err := localserver.NewFileStore(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ver := range []int{1, 3} {
+		if _, _, err := fst2.Unveil(testVaultID, "foo", ver); !errors.Is(err, localserver.ErrDecrypt) {
+			t.Errorf("expected ErrDecrypt for version %d, got %v", ver, err)
+		}
+	}
+	if value, _, err := fst2.Unveil(testVaultID, "foo", 2); err != nil || value != "value-2" {
+		t.Errorf("unexpected unveil result for version 2: %q, %v", value, err)
+	}
+}
+
+func TestFileStoreSwappedVault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	key := newTestMasterKey(t)
+	fst, err := localserver.NewFileStore(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vaultID := range []string{"vault-a", "vault-b"} {
+		if _, err := fst.Create(vaultID, "foo", "value of "+vaultID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Swap the secrets of the same name between the vaults
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]any
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+	vaults := data["Vaults"].([]any)
+	va, vb := vaults[0].(map[string]any), vaults[1].(map[string]any)
+	va["Secrets"], vb["Secrets"] = vb["Secrets"], va["Secrets"]
+	b, err = json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fst2, err := localserver.NewFileStore(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vaultID := range []string{"vault-a", "vault-b"} {
+		if _, _, err := fst2.Unveil(vaultID, "foo", 1); !errors.Is(err, localserver.ErrDecrypt) {
+			t.Errorf("expected ErrDecrypt for %s, got %v", vaultID, err)
+		}
+	}
+}
+
+func TestFileStoreRollback(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	fst, err := localserver.NewFileStoreInDir(dir, newTestMasterKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fst.Create(testVaultID, "foo", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	// saving fails after the data directory is removed
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fst.Create(testVaultID, "foo", "v2"); err == nil {
+		t.Fatal("expected error for failed save")
+	}
+	if _, err := fst.Create(testVaultID, "bar", "v1"); err == nil {
+		t.Fatal("expected error for failed save")
+	}
+	if err := fst.Delete(testVaultID, "foo"); err == nil {
+		t.Fatal("expected error for failed save")
+	}
+	if _, err := fst.CreateVault(localserver.VaultMeta{Name: "v"}); err == nil {
+		t.Fatal("expected error for failed save")
+	}
+
+	// the failed mutations are not visible
+	list, err := fst.List(testVaultID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "foo" || list[0].LatestVersion != 1 {
+		t.Errorf("unexpected secrets: %+v", list)
+	}
+	if n := len(fst.ListVaults()); n != 1 {
+		t.Errorf("unexpected number of vaults: %d", n)
+	}
+	if value, _, err := fst.Unveil(testVaultID, "foo", 0); err != nil || value != "v1" {
+		t.Errorf("unexpected value: %q, %v", value, err)
+	}
+}
@@ -0,0 +1,97 @@
+package localserver
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// requestLogSize is the maximum number of entries kept in the request log.
+const requestLogSize = 1000
+
+// RequestLogEntry is a record of an API request.
+// Request and response bodies are not recorded, so secret values never appear in the log.
+type RequestLogEntry struct {
+	Time      time.Time `json:"Time"`
+	Method    string    `json:"Method"`
+	Path      string    `json:"Path"`
+	Operation string    `json:"Operation"`
+	VaultID   string    `json:"VaultID,omitempty"`
+	Status    int       `json:"Status"`
+	// Dropped is true when no response was sent, e.g. the connection was dropped by fault injection.
+	Dropped  bool     `json:"Dropped,omitempty"`
+	Duration Duration `json:"Duration"`
+}
+
+// requestLog keeps the latest requestLogSize entries.
+type requestLog struct {
+	mu      sync.Mutex
+	entries []RequestLogEntry
+}
+
+func (l *requestLog) add(e RequestLogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) >= requestLogSize {
+		l.entries = append(l.entries[:0], l.entries[len(l.entries)-requestLogSize+1:]...)
+	}
+	l.entries = append(l.entries, e)
+}
+
+func (l *requestLog) list() []RequestLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]RequestLogEntry{}, l.entries...)
+}
+
+func (l *requestLog) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = nil
+}
+
+// wrap returns a handler that records requests to h.
+func (l *requestLog) wrap(operation string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			l.add(RequestLogEntry{
+				Time:      start,
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Operation: operation,
+				VaultID:   r.PathValue("vault_id"),
+				Status:    sw.status,
+				Dropped:   sw.status == 0,
+				Duration:  Duration(time.Since(start)),
+			})
+		}()
+		h(sw, r)
+	}
+}
+
+// statusWriter records the status code written to a ResponseWriter.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap is used by http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
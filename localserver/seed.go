@@ -0,0 +1,201 @@
+package localserver
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Seed describes vaults and secrets to be loaded into a Backend at startup.
+//
+// A seed file is YAML or JSON like:
+//
+//	vaults:
+//	  - id: my-vault
+//	    name: My vault
+//	    secrets:
+//	      - name: db_password
+//	        versions: ["old-password", "new-password"]
+//	      - name: api_key
+//	        value: "abcdef"
+type Seed struct {
+	Vaults []SeedVault `yaml:"vaults"`
+}
+
+// SeedVault is a vault in a Seed.
+type SeedVault struct {
+	ID          string       `yaml:"id"`
+	Name        string       `yaml:"name"`
+	Description string       `yaml:"description"`
+	KmsKeyID    string       `yaml:"kms_key_id"`
+	Tags        []string     `yaml:"tags"`
+	Secrets     []SeedSecret `yaml:"secrets"`
+}
+
+// SeedSecret is a secret in a SeedVault.
+// Versions are created in order; Value is a shorthand for a single version.
+type SeedSecret struct {
+	Name     string   `yaml:"name"`
+	Value    *string  `yaml:"value"`
+	Versions []string `yaml:"versions"`
+}
+
+var seedFileExts = []string{".yaml", ".yml", ".json"}
+
+// LoadSeed loads a Seed from path.
+// If path is a file, it must contain a Seed.
+// If path is a directory, each YAML or JSON file in it must contain a single SeedVault.
+// The vault ID defaults to the file name without extension.
+func LoadSeed(path string) (*Seed, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed: %w", err)
+	}
+	if !fi.IsDir() {
+		var sd Seed
+		if err := readSeedFile(path, &sd); err != nil {
+			return nil, err
+		}
+		return &sd, sd.validate()
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed directory: %w", err)
+	}
+	sd := &Seed{}
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || !isSeedFileExt(ext) {
+			continue
+		}
+		var v SeedVault
+		if err := readSeedFile(filepath.Join(path, e.Name()), &v); err != nil {
+			return nil, err
+		}
+		if v.ID == "" {
+			v.ID = strings.TrimSuffix(e.Name(), ext)
+		}
+		sd.Vaults = append(sd.Vaults, v)
+	}
+	return sd, sd.validate()
+}
+
+func isSeedFileExt(ext string) bool {
+	for _, e := range seedFileExts {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func readSeedFile(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read seed file: %w", err)
+	}
+	// YAML is a superset of JSON, so JSON files are parsed as well.
+	if err := yaml.UnmarshalWithOptions(b, v, yaml.Strict()); err != nil {
+		return fmt.Errorf("failed to parse seed file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (sd *Seed) validate() error {
+	ids := make(map[string]bool, len(sd.Vaults))
+	for i, v := range sd.Vaults {
+		if v.ID == "" {
+			return fmt.Errorf("seed vaults[%d]: id is required", i)
+		}
+		if ids[v.ID] {
+			return fmt.Errorf("seed vault %q: duplicated", v.ID)
+		}
+		ids[v.ID] = true
+		names := make(map[string]bool, len(v.Secrets))
+		for j, sec := range v.Secrets {
+			if sec.Name == "" {
+				return fmt.Errorf("seed vault %q secrets[%d]: name is required", v.ID, j)
+			}
+			if names[sec.Name] {
+				return fmt.Errorf("seed vault %q secret %q: duplicated", v.ID, sec.Name)
+			}
+			names[sec.Name] = true
+			if sec.Value != nil && len(sec.Versions) > 0 {
+				return fmt.Errorf("seed vault %q secret %q: value and versions are mutually exclusive", v.ID, sec.Name)
+			}
+			if sec.Value == nil && len(sec.Versions) == 0 {
+				return fmt.Errorf("seed vault %q secret %q: value or versions is required", v.ID, sec.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply loads the seed into b.
+// Vaults that already exist are reused, and secrets that already exist are left untouched,
+// so applying the same seed to a persisted backend again does not add versions.
+func (sd *Seed) Apply(b Backend) error {
+	for _, v := range sd.Vaults {
+		if _, err := b.GetVault(v.ID); errors.Is(err, ErrNotFound) {
+			name := v.Name
+			if name == "" {
+				name = v.ID
+			}
+			if _, err := b.CreateVault(VaultMeta{
+				ID:          v.ID,
+				Name:        name,
+				Description: v.Description,
+				KmsKeyID:    v.KmsKeyID,
+				Tags:        v.Tags,
+			}); err != nil {
+				return fmt.Errorf("failed to create seed vault %q: %w", v.ID, err)
+			}
+		} else if err != nil {
+			return err
+		}
+
+		existing, err := b.List(v.ID)
+		if err != nil {
+			return err
+		}
+		exists := make(map[string]bool, len(existing))
+		for _, m := range existing {
+			exists[m.Name] = true
+		}
+		for _, sec := range v.Secrets {
+			if exists[sec.Name] {
+				log.Printf("seed: secret %q in vault %q already exists, skipped", sec.Name, v.ID)
+				continue
+			}
+			versions := sec.Versions
+			if sec.Value != nil {
+				versions = []string{*sec.Value}
+			}
+			for _, value := range versions {
+				if _, err := b.Create(v.ID, sec.Name, value); err != nil {
+					return fmt.Errorf("failed to create seed secret %q in vault %q: %w", sec.Name, v.ID, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Summary returns a short description of the seed, like "2 vaults, 5 secrets".
+func (sd *Seed) Summary() string {
+	secrets := 0
+	ids := make([]string, 0, len(sd.Vaults))
+	for _, v := range sd.Vaults {
+		secrets += len(v.Secrets)
+		ids = append(ids, v.ID)
+	}
+	sort.Strings(ids)
+	return fmt.Sprintf("%d vaults (%s), %d secrets", len(sd.Vaults), strings.Join(ids, ", "), secrets)
+}
@@ -0,0 +1,126 @@
+package localserver_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fujiwara/sakura-secrets-cli/localserver"
+)
+
+const testSeedYAML = `
+vaults:
+  - id: vault-a
+    name: Vault A
+    kms_key_id: kms-1
+    tags: [dev]
+    secrets:
+      - name: db_password
+        versions: ["old", "new"]
+      - name: api_key
+        value: "abc"
+  - id: vault-b
+    secrets:
+      - name: token
+        value: "xyz"
+`
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertUnveil(t *testing.T, b localserver.Backend, vaultID, name string, version int, want string) {
+	t.Helper()
+	got, _, err := b.Unveil(vaultID, name, version)
+	if err != nil {
+		t.Fatalf("Unveil(%s, %s, %d): %v", vaultID, name, version, err)
+	}
+	if got != want {
+		t.Errorf("Unveil(%s, %s, %d) = %q, want %q", vaultID, name, version, got, want)
+	}
+}
+
+func TestSeedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seed.yaml")
+	writeTestFile(t, path, testSeedYAML)
+
+	sd, err := localserver.LoadSeed(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := localserver.NewStore()
+	store.SetStrict(true)
+	if err := sd.Apply(store); err != nil {
+		t.Fatal(err)
+	}
+
+	assertUnveil(t, store, "vault-a", "db_password", 1, "old")
+	assertUnveil(t, store, "vault-a", "db_password", 0, "new")
+	assertUnveil(t, store, "vault-a", "api_key", 0, "abc")
+	assertUnveil(t, store, "vault-b", "token", 0, "xyz")
+
+	meta, err := store.GetVault("vault-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Name != "Vault A" || meta.KmsKeyID != "kms-1" || len(meta.Tags) != 1 {
+		t.Errorf("unexpected vault: %+v", meta)
+	}
+
+	// Applying again does not add versions
+	if err := sd.Apply(store); err != nil {
+		t.Fatal(err)
+	}
+	secrets, err := store.List("vault-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range secrets {
+		if s.Name == "db_password" && s.LatestVersion != 2 {
+			t.Errorf("unexpected latest version after re-seeding: %d", s.LatestVersion)
+		}
+	}
+}
+
+func TestSeedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "vault-a.yaml"), `
+secrets:
+  - name: foo
+    value: "bar"
+`)
+	writeTestFile(t, filepath.Join(dir, "other.json"), `{"id": "vault-b", "secrets": [{"name": "baz", "versions": ["1", "2"]}]}`)
+	writeTestFile(t, filepath.Join(dir, "README.md"), "ignored")
+
+	sd, err := localserver.LoadSeed(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := localserver.NewStore()
+	if err := sd.Apply(store); err != nil {
+		t.Fatal(err)
+	}
+	assertUnveil(t, store, "vault-a", "foo", 0, "bar")
+	assertUnveil(t, store, "vault-b", "baz", 2, "2")
+}
+
+func TestSeedInvalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":   "vaults:\n  - id: a\n    secret: []\n",
+		"missing id":      "vaults:\n  - name: a\n",
+		"missing value":   "vaults:\n  - id: a\n    secrets:\n      - name: foo\n",
+		"duplicated name": "vaults:\n  - id: a\n    secrets:\n      - {name: foo, value: x}\n      - {name: foo, value: y}\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "seed.yaml")
+			writeTestFile(t, path, content)
+			if _, err := localserver.LoadSeed(path); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
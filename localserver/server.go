@@ -2,10 +2,12 @@ package localserver
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 )
 
 // JSON request/response types matching the OpenAPI spec.
@@ -62,26 +64,159 @@ type paginatedSecretList struct {
 	Secrets []secretResponse `json:"Secrets"`
 }
 
+type vaultResponse struct {
+	ID          string   `json:"ID"`
+	CreatedAt   string   `json:"CreatedAt"`
+	ModifiedAt  string   `json:"ModifiedAt"`
+	Name        string   `json:"Name"`
+	Description string   `json:"Description"`
+	KmsKeyID    string   `json:"KmsKeyID"`
+	Tags        []string `json:"Tags"`
+}
+
+type wrappedVault struct {
+	Vault vaultResponse `json:"Vault"`
+}
+
+type paginatedVaultList struct {
+	Count  int             `json:"Count"`
+	From   int             `json:"From"`
+	Total  int             `json:"Total"`
+	Vaults []vaultResponse `json:"Vaults"`
+}
+
+// dateTimeFormat is the ISO 8601 format used by the API, e.g. "2025-02-05T12:19:22.551827+09:00".
+const dateTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+func newVaultResponse(m VaultMeta) vaultResponse {
+	tags := m.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return vaultResponse{
+		ID:          m.ID,
+		CreatedAt:   m.CreatedAt.Format(dateTimeFormat),
+		ModifiedAt:  m.ModifiedAt.Format(dateTimeFormat),
+		Name:        m.Name,
+		Description: m.Description,
+		KmsKeyID:    m.KmsKeyID,
+		Tags:        tags,
+	}
+}
+
 // Server is the local SecretManager API server.
 type Server struct {
-	store  *Store
+	store  Backend
 	mux    *http.ServeMux
 	prefix string
+	strict bool
+	admin  bool
+	faults faults
+	log    requestLog
+
+	credentials []Credential
+	validator   *validator
+	maxPageSize int
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithStrictVaults makes secret operations against a vault that was never
+// created return 404, as the real service does.
+// By default any vault ID is accepted and created implicitly.
+func WithStrictVaults() Option {
+	return func(s *Server) {
+		s.strict = true
+	}
+}
+
+// WithFaults sets the initial latency and fault injection configuration.
+// It panics if c is invalid; use FaultConfig.Validate beforehand.
+func WithFaults(c FaultConfig) Option {
+	return func(s *Server) {
+		if err := s.faults.set(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// WithAdmin enables the admin API under AdminPrefix.
+func WithAdmin() Option {
+	return func(s *Server) {
+		s.admin = true
+	}
+}
+
+// WithCredentials makes the server require HTTP basic auth with one of creds.
+// By default any request is accepted.
+// The admin API requires one of creds that is not scoped to vaults.
+func WithCredentials(creds ...Credential) Option {
+	return func(s *Server) {
+		s.credentials = append(s.credentials, creds...)
+	}
+}
+
+// WithValidation makes the server validate every request against OpenAPISpec,
+// and respond with 400 for invalid requests.
+// If responses is true, responses are validated as well, and responses that do not
+// conform to the spec are replaced with 500, so the server cannot drift from the spec silently.
+func WithValidation(responses bool) Option {
+	return func(s *Server) {
+		v, err := newValidator(responses)
+		if err != nil {
+			panic(err) // OpenAPISpec is embedded, so this never happens
+		}
+		s.validator = v
+	}
+}
+
+// WithMaxPageSize caps the number of items returned by a list operation.
+// Clients can page through the rest with the From and Count query parameters.
+// 0 means no limit (default).
+func WithMaxPageSize(n int) Option {
+	return func(s *Server) {
+		s.maxPageSize = n
+	}
+}
+
+// WithBackend sets the storage backend. The default is an in-memory Store.
+func WithBackend(b Backend) Option {
+	return func(s *Server) {
+		s.store = b
+	}
 }
 
 // NewServer creates a new Server with the given path prefix.
 // prefix should be like "/api/cloud/1.1" (no trailing slash).
-func NewServer(prefix string) *Server {
+func NewServer(prefix string, opts ...Option) *Server {
 	s := &Server{
 		store:  NewStore(),
 		mux:    http.NewServeMux(),
 		prefix: prefix,
 	}
-	base := prefix + "/secretmanager/vaults/{vault_id}"
-	s.mux.HandleFunc("GET "+base+"/secrets", s.handleListSecrets)
-	s.mux.HandleFunc("POST "+base+"/secrets", s.handleCreateSecret)
-	s.mux.HandleFunc("DELETE "+base+"/secrets", s.handleDeleteSecret)
-	s.mux.HandleFunc("POST "+base+"/secrets/unveil", s.handleUnveil)
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.strict {
+		s.store.SetStrict(true)
+	}
+	vaults := prefix + "/secretmanager/vaults"
+	s.handle("GET "+vaults, "secretmanager_vaults_list", s.handleListVaults)
+	s.handle("POST "+vaults, "secretmanager_vaults_create", s.handleCreateVault)
+	s.handle("GET "+vaults+"/{vault_id}", "secretmanager_vaults_retrieve", s.handleGetVault)
+	s.handle("PUT "+vaults+"/{vault_id}", "secretmanager_vaults_update", s.handleUpdateVault)
+	s.handle("DELETE "+vaults+"/{vault_id}", "secretmanager_vaults_destroy", s.handleDeleteVault)
+
+	base := vaults + "/{vault_id}"
+	s.handle("GET "+base+"/secrets", "secretmanager_vaults_secrets_list", s.handleListSecrets)
+	s.handle("POST "+base+"/secrets", "secretmanager_vaults_secrets_create", s.handleCreateSecret)
+	s.handle("DELETE "+base+"/secrets", "secretmanager_vaults_secrets_destroy", s.handleDeleteSecret)
+	s.handle("POST "+base+"/secrets/unveil", "secretmanager_vaults_secrets_unveil", s.handleUnveil)
+
+	if s.admin {
+		s.registerAdminRoutes()
+	}
 	return s
 }
 
@@ -89,18 +224,164 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.mux.ServeHTTP(w, r)
 }
 
+// handle registers an API handler for the operation (operationId in openapi.json).
+func (s *Server) handle(pattern, operation string, h http.HandlerFunc) {
+	if s.validator != nil {
+		h = s.validator.wrap(operation, h)
+	}
+	s.mux.HandleFunc(pattern, s.log.wrap(operation, s.faults.wrap(operation, s.authenticate(operation, h))))
+}
+
+// RequestLog returns the latest API requests, oldest first.
+func (s *Server) RequestLog() []RequestLogEntry {
+	return s.log.list()
+}
+
+// Faults returns the current latency and fault injection configuration.
+func (s *Server) Faults() FaultConfig {
+	return s.faults.get()
+}
+
+// SetFaults replaces the latency and fault injection configuration.
+func (s *Server) SetFaults(c FaultConfig) error {
+	return s.faults.set(c)
+}
+
+// page returns the range [from, to) of total items requested by the From and Count query parameters,
+// capped by the max page size.
+func (s *Server) page(r *http.Request, total int) (from, to int, err error) {
+	q := r.URL.Query()
+	if v := q.Get("From"); v != "" {
+		from, err = strconv.Atoi(v)
+		if err != nil || from < 0 {
+			return 0, 0, fmt.Errorf("invalid From: %q", v)
+		}
+	}
+	count := total
+	if v := q.Get("Count"); v != "" {
+		count, err = strconv.Atoi(v)
+		if err != nil || count < 0 {
+			return 0, 0, fmt.Errorf("invalid Count: %q", v)
+		}
+	}
+	if s.maxPageSize > 0 && count > s.maxPageSize {
+		count = s.maxPageSize
+	}
+	from = min(from, total)
+	return from, min(from+count, total), nil
+}
+
+func (s *Server) handleListVaults(w http.ResponseWriter, r *http.Request) {
+	cred := credentialFrom(r.Context())
+	vaults := s.store.ListVaults()
+	items := make([]vaultResponse, 0, len(vaults))
+	for _, v := range vaults {
+		if cred != nil && !cred.allows(v.ID) {
+			continue
+		}
+		items = append(items, newVaultResponse(v))
+	}
+	from, to, err := s.page(r, len(items))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := paginatedVaultList{
+		Count:  to - from,
+		From:   from,
+		Total:  len(items),
+		Vaults: items[from:to],
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleCreateVault(w http.ResponseWriter, r *http.Request) {
+	var req wrappedVault
+	if err := readJSON(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Vault.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	if req.Vault.KmsKeyID == "" {
+		http.Error(w, "KmsKeyID is required", http.StatusBadRequest)
+		return
+	}
+	meta, err := s.store.CreateVault(VaultMeta{
+		Name:        req.Vault.Name,
+		Description: req.Vault.Description,
+		KmsKeyID:    req.Vault.KmsKeyID,
+		Tags:        req.Vault.Tags,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, wrappedVault{Vault: newVaultResponse(meta)})
+}
+
+func (s *Server) handleGetVault(w http.ResponseWriter, r *http.Request) {
+	meta, err := s.store.GetVault(r.PathValue("vault_id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, wrappedVault{Vault: newVaultResponse(meta)})
+}
+
+func (s *Server) handleUpdateVault(w http.ResponseWriter, r *http.Request) {
+	var req wrappedVault
+	if err := readJSON(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Vault.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	meta, err := s.store.UpdateVault(r.PathValue("vault_id"), VaultMeta{
+		Name:        req.Vault.Name,
+		Description: req.Vault.Description,
+		Tags:        req.Vault.Tags,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, wrappedVault{Vault: newVaultResponse(meta)})
+}
+
+func (s *Server) handleDeleteVault(w http.ResponseWriter, r *http.Request) {
+	if err := s.store.DeleteVault(r.PathValue("vault_id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (s *Server) handleListSecrets(w http.ResponseWriter, r *http.Request) {
 	vaultID := r.PathValue("vault_id")
-	secrets := s.store.List(vaultID)
+	secrets, err := s.store.List(vaultID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 	items := make([]secretResponse, len(secrets))
 	for i, sec := range secrets {
 		items[i] = secretResponse{Name: sec.Name, LatestVersion: sec.LatestVersion}
 	}
+	from, to, err := s.page(r, len(items))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	resp := paginatedSecretList{
-		Count:   len(items),
-		From:    0,
+		Count:   to - from,
+		From:    from,
 		Total:   len(items),
-		Secrets: items,
+		Secrets: items[from:to],
 	}
 	writeJSON(w, http.StatusOK, resp)
 }
@@ -118,7 +399,7 @@ func (s *Server) handleCreateSecret(w http.ResponseWriter, r *http.Request) {
 	}
 	latestVersion, err := s.store.Create(vaultID, req.Secret.Name, req.Secret.Value)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	resp := wrappedSecret{
@@ -135,7 +416,7 @@ func (s *Server) handleDeleteSecret(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err := s.store.Delete(vaultID, req.Secret.Name); err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		writeError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -154,7 +435,7 @@ func (s *Server) handleUnveil(w http.ResponseWriter, r *http.Request) {
 	}
 	value, actualVersion, err := s.store.Unveil(vaultID, req.Secret.Name, version)
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		writeError(w, err)
 		return
 	}
 	resp := wrappedUnveilResponse{
@@ -179,6 +460,16 @@ func readJSON(r *http.Request, v any) error {
 	return nil
 }
 
+// writeError writes err as a JSON error response.
+// Errors wrapping ErrNotFound are reported as 404, others as 500.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrNotFound) {
+		status = http.StatusNotFound
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -1,7 +1,10 @@
 package localserver_test
 
 import (
+	"encoding/json"
+	"net/http"
 	"net/http/httptest"
+	"slices"
 	"sort"
 	"testing"
 
@@ -15,13 +18,13 @@ import (
 const testPrefix = "/api/cloud/1.1"
 const testVaultID = "test-vault-123"
 
-func newTestSecretOp(t *testing.T, serverURL, vaultID string) sm.SecretAPI {
+func newTestClient(t *testing.T, serverURL, token, secret string) *v1.Client {
 	t.Helper()
 	var sa saclient.Client
 	if err := sa.SetEnviron([]string{
 		"SAKURA_API_ROOT_URL=" + serverURL + testPrefix,
-		"SAKURA_ACCESS_TOKEN=dummy",
-		"SAKURA_ACCESS_TOKEN_SECRET=dummy",
+		"SAKURA_ACCESS_TOKEN=" + token,
+		"SAKURA_ACCESS_TOKEN_SECRET=" + secret,
 	}); err != nil {
 		t.Fatal(err)
 	}
@@ -29,7 +32,17 @@ func newTestSecretOp(t *testing.T, serverURL, vaultID string) sm.SecretAPI {
 	if err != nil {
 		t.Fatal(err)
 	}
-	return sm.NewSecretOp(client, vaultID)
+	return client
+}
+
+func newTestSecretOp(t *testing.T, serverURL, vaultID string) sm.SecretAPI {
+	t.Helper()
+	return sm.NewSecretOp(newTestClient(t, serverURL, "dummy", "dummy"), vaultID)
+}
+
+func newTestVaultOp(t *testing.T, serverURL string) sm.VaultAPI {
+	t.Helper()
+	return sm.NewVaultOp(newTestClient(t, serverURL, "dummy", "dummy"))
 }
 
 func TestSecretLifecycle(t *testing.T) {
@@ -202,3 +215,173 @@ func TestDifferentVaults(t *testing.T) {
 		t.Fatalf("vault-1 should have 1 secret, got %d", len(secrets1))
 	}
 }
+
+func TestVaultLifecycle(t *testing.T) {
+	srv := httptest.NewServer(localserver.NewServer(testPrefix))
+	defer srv.Close()
+	ctx := t.Context()
+	vaultOp := newTestVaultOp(t, srv.URL)
+
+	// List: initially empty
+	vaults, err := vaultOp.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vaults) != 0 {
+		t.Fatalf("expected 0 vaults, got %d", len(vaults))
+	}
+
+	// Create
+	created, err := vaultOp.Create(ctx, v1.CreateVault{
+		Name:        "my-vault",
+		Description: v1.NewOptString("for test"),
+		KmsKeyID:    "kms-1",
+		Tags:        []string{"a", "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || created.Name != "my-vault" || created.KmsKeyID != "kms-1" || created.CreatedAt == "" {
+		t.Fatalf("unexpected create response: %+v", created)
+	}
+	if created.Description.Value != "for test" || len(created.Tags) != 2 {
+		t.Fatalf("unexpected create response: %+v", created)
+	}
+
+	// Read
+	got, err := vaultOp.Read(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != created.ID || got.Name != "my-vault" {
+		t.Fatalf("unexpected read response: %+v", got)
+	}
+
+	// Update
+	updated, err := vaultOp.Update(ctx, created.ID, v1.Vault{
+		Name:        "renamed",
+		Description: v1.NewOptString("updated"),
+		Tags:        []string{"c"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "renamed" || updated.Description.Value != "updated" || len(updated.Tags) != 1 || updated.KmsKeyID != "kms-1" {
+		t.Fatalf("unexpected update response: %+v", updated)
+	}
+
+	// List: 1 vault
+	vaults, err = vaultOp.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vaults) != 1 || vaults[0].Name != "renamed" {
+		t.Fatalf("unexpected vaults: %+v", vaults)
+	}
+
+	// Delete
+	if err := vaultOp.Delete(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vaultOp.Read(ctx, created.ID); err == nil {
+		t.Fatal("expected error for deleted vault")
+	}
+	if err := vaultOp.Delete(ctx, created.ID); err == nil {
+		t.Fatal("expected error for deleting non-existent vault")
+	}
+}
+
+func TestStrictVaults(t *testing.T) {
+	srv := httptest.NewServer(localserver.NewServer(testPrefix, localserver.WithStrictVaults()))
+	defer srv.Close()
+	ctx := t.Context()
+
+	// Secret operations against an unknown vault fail
+	secOp := newTestSecretOp(t, srv.URL, "unknown-vault")
+	if _, err := secOp.List(ctx); err == nil {
+		t.Fatal("expected error for listing secrets in unknown vault")
+	}
+	if _, err := secOp.Create(ctx, v1.CreateSecret{Name: "foo", Value: "bar"}); err == nil {
+		t.Fatal("expected error for creating secret in unknown vault")
+	}
+
+	// Secret operations against a created vault succeed
+	vault, err := newTestVaultOp(t, srv.URL).Create(ctx, v1.CreateVault{Name: "v", KmsKeyID: "kms-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secOp = newTestSecretOp(t, srv.URL, vault.ID)
+	if _, err := secOp.Create(ctx, v1.CreateSecret{Name: "foo", Value: "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	unveiled, err := secOp.Unveil(ctx, v1.Unveil{Name: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unveiled.Value != "bar" {
+		t.Fatalf("unexpected unveil response: %+v", unveiled)
+	}
+}
+
+func TestListPaging(t *testing.T) {
+	srv := httptest.NewServer(localserver.NewServer(testPrefix, localserver.WithMaxPageSize(2)))
+	defer srv.Close()
+	ctx := t.Context()
+	secOp := newTestSecretOp(t, srv.URL, testVaultID)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		if _, err := secOp.Create(ctx, v1.CreateSecret{Name: name, Value: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		from  int
+		names []string
+	}{
+		{"", 0, []string{"a", "b"}},
+		{"?From=2", 2, []string{"c", "d"}},
+		{"?From=4", 4, []string{"e"}},
+		{"?From=1&Count=1", 1, []string{"b"}},
+		{"?From=1&Count=10", 1, []string{"b", "c"}},
+		{"?From=10", 5, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + testPrefix + "/secretmanager/vaults/" + testVaultID + "/secrets" + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("unexpected status: %d", resp.StatusCode)
+			}
+			var list struct {
+				Count, From, Total int
+				Secrets            []v1.Secret
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, s := range list.Secrets {
+				names = append(names, s.Name)
+			}
+			if !slices.Equal(names, tt.names) {
+				t.Errorf("unexpected names: %v", names)
+			}
+			if list.Total != 5 || list.From != tt.from || list.Count != len(tt.names) {
+				t.Errorf("unexpected paging: count=%v from=%v total=%v", list.Count, list.From, list.Total)
+			}
+		})
+	}
+
+	resp, err := http.Get(srv.URL + testPrefix + "/secretmanager/vaults/" + testVaultID + "/secrets?From=-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unexpected status for invalid From: %d", resp.StatusCode)
+	}
+}
@@ -1,58 +1,253 @@
 package localserver
 
 import (
+	"bytes"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 )
 
+// ErrNotFound is returned (wrapped) when a vault, secret or version does not exist.
+var ErrNotFound = errors.New("not found")
+
 // SecretMeta represents secret metadata returned by List.
 type SecretMeta struct {
 	Name          string
 	LatestVersion int
 }
 
-// Store is an in-memory store for secrets with versioning and simple XOR encryption.
+// VaultMeta represents vault metadata.
+type VaultMeta struct {
+	ID          string
+	Name        string
+	Description string
+	KmsKeyID    string
+	Tags        []string
+	CreatedAt   time.Time
+	ModifiedAt  time.Time
+}
+
+// Backend is a storage backend for Server.
+// Store (in-memory) and FileStore (persisted to a file) implement it.
+type Backend interface {
+	// SetStrict enables or disables strict vault mode.
+	SetStrict(strict bool)
+
+	ListVaults() []VaultMeta
+	GetVault(vaultID string) (VaultMeta, error)
+	CreateVault(meta VaultMeta) (VaultMeta, error)
+	UpdateVault(vaultID string, meta VaultMeta) (VaultMeta, error)
+	DeleteVault(vaultID string) error
+
+	List(vaultID string) ([]SecretMeta, error)
+	Create(vaultID, name, value string) (int, error)
+	Unveil(vaultID, name string, version int) (string, int, error)
+	Delete(vaultID, name string) error
+
+	// Snapshot returns a copy of the whole contents.
+	Snapshot() *Snapshot
+	// Restore replaces the whole contents with snap.
+	// Restoring an empty Snapshot removes all vaults and secrets.
+	Restore(snap *Snapshot) error
+}
+
+var _ Backend = (*Store)(nil)
+
+// Store is an in-memory store for secrets with versioning and envelope encryption.
+// Each secret has its own data key, which is wrapped by the store's master key.
+// Values are encrypted with AES-256-GCM.
 type Store struct {
-	mu     sync.RWMutex
-	vaults map[string]map[string]*secret // vaultID -> secretName -> secret
+	mu        sync.RWMutex
+	vaults    map[string]*vault // vaultID -> vault
+	nextID    int64
+	masterKey []byte
+
+	// strict makes secret operations fail with ErrNotFound for vaults
+	// that were never created, instead of creating them implicitly.
+	strict bool
+}
+
+type vault struct {
+	meta    VaultMeta
+	secrets map[string]*secret // secretName -> secret
 }
 
 type secret struct {
 	name     string
-	versions map[int][]byte // version -> XOR-encrypted value
+	versions map[int][]byte // version -> encrypted value
 	latest   int
-	key      []byte // XOR key
+	key      []byte // data key, wrapped by the master key
 }
 
-// NewStore creates a new empty Store.
+// NewStore creates a new empty Store with a random master key.
 func NewStore() *Store {
+	key, err := GenerateMasterKey()
+	if err != nil {
+		panic(err)
+	}
+	s, err := NewStoreWithKey(key)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// NewStoreWithKey creates a new empty Store with the given master key.
+// The master key must be MasterKeySize bytes.
+func NewStoreWithKey(masterKey []byte) (*Store, error) {
+	if len(masterKey) != MasterKeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d bytes", MasterKeySize, len(masterKey))
+	}
 	return &Store{
-		vaults: make(map[string]map[string]*secret),
+		vaults:    make(map[string]*vault),
+		nextID:    110000000000,
+		masterKey: bytes.Clone(masterKey),
+	}, nil
+}
+
+// SetStrict enables or disables strict vault mode.
+// In strict mode, secret operations against a vault that was never created return ErrNotFound.
+func (s *Store) SetStrict(strict bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strict = strict
+}
+
+func newVault(meta VaultMeta) *vault {
+	return &vault{
+		meta:    meta,
+		secrets: make(map[string]*secret),
 	}
 }
 
-func (s *Store) getOrCreateVault(vaultID string) map[string]*secret {
+func (s *Store) getOrCreateVault(vaultID string) (*vault, error) {
 	v, ok := s.vaults[vaultID]
 	if !ok {
-		v = make(map[string]*secret)
+		if s.strict {
+			return nil, fmt.Errorf("vault %q %w", vaultID, ErrNotFound)
+		}
+		now := time.Now()
+		v = newVault(VaultMeta{
+			ID:         vaultID,
+			Name:       vaultID,
+			Tags:       []string{},
+			CreatedAt:  now,
+			ModifiedAt: now,
+		})
 		s.vaults[vaultID] = v
 	}
-	return v
+	return v, nil
 }
 
-// List returns all secret metadata for a vault.
-func (s *Store) List(vaultID string) []SecretMeta {
+// getVault returns the vault for secret read operations.
+// A missing vault is treated as empty unless strict mode is enabled.
+func (s *Store) getVault(vaultID string) (*vault, error) {
+	v, ok := s.vaults[vaultID]
+	if !ok {
+		if s.strict {
+			return nil, fmt.Errorf("vault %q %w", vaultID, ErrNotFound)
+		}
+		return newVault(VaultMeta{ID: vaultID}), nil
+	}
+	return v, nil
+}
+
+// ListVaults returns metadata of all vaults, sorted by ID.
+func (s *Store) ListVaults() []VaultMeta {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]VaultMeta, 0, len(s.vaults))
+	for _, v := range s.vaults {
+		result = append(result, v.meta.clone())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// GetVault returns metadata of a vault.
+func (s *Store) GetVault(vaultID string) (VaultMeta, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	vault, ok := s.vaults[vaultID]
+	v, ok := s.vaults[vaultID]
+	if !ok {
+		return VaultMeta{}, fmt.Errorf("vault %q %w", vaultID, ErrNotFound)
+	}
+	return v.meta.clone(), nil
+}
+
+// CreateVault creates a new vault. CreatedAt and ModifiedAt of meta are assigned by the store.
+// If meta.ID is empty, a new ID is assigned.
+func (s *Store) CreateVault(meta VaultMeta) (VaultMeta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if meta.ID == "" {
+		for {
+			s.nextID++
+			meta.ID = fmt.Sprintf("%012d", s.nextID)
+			if _, exists := s.vaults[meta.ID]; !exists {
+				break
+			}
+		}
+	} else if _, exists := s.vaults[meta.ID]; exists {
+		return VaultMeta{}, fmt.Errorf("vault %q already exists", meta.ID)
+	}
+	now := time.Now()
+	meta.CreatedAt = now
+	meta.ModifiedAt = now
+	if meta.Tags == nil {
+		meta.Tags = []string{}
+	}
+	s.vaults[meta.ID] = newVault(meta.clone())
+	return meta, nil
+}
+
+// UpdateVault replaces the mutable fields (Name, Description and Tags) of a vault.
+func (s *Store) UpdateVault(vaultID string, meta VaultMeta) (VaultMeta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.vaults[vaultID]
 	if !ok {
-		return nil
+		return VaultMeta{}, fmt.Errorf("vault %q %w", vaultID, ErrNotFound)
+	}
+	v.meta.Name = meta.Name
+	v.meta.Description = meta.Description
+	v.meta.Tags = append([]string{}, meta.Tags...)
+	v.meta.ModifiedAt = time.Now()
+	return v.meta.clone(), nil
+}
+
+// DeleteVault removes a vault and all of its secrets.
+func (s *Store) DeleteVault(vaultID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.vaults[vaultID]; !ok {
+		return fmt.Errorf("vault %q %w", vaultID, ErrNotFound)
+	}
+	delete(s.vaults, vaultID)
+	return nil
+}
+
+// List returns all secret metadata for a vault.
+func (s *Store) List(vaultID string) ([]SecretMeta, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	vault, err := s.getVault(vaultID)
+	if err != nil {
+		return nil, err
 	}
-	result := make([]SecretMeta, 0, len(vault))
-	for _, sec := range vault {
+	result := make([]SecretMeta, 0, len(vault.secrets))
+	for _, sec := range vault.secrets {
 		result = append(result, SecretMeta{
 			Name:          sec.name,
 			LatestVersion: sec.latest,
@@ -61,7 +256,7 @@ func (s *Store) List(vaultID string) []SecretMeta {
 	sort.Slice(result, func(i, j int) bool {
 		return result[i].Name < result[j].Name
 	})
-	return result
+	return result, nil
 }
 
 // Create creates a new secret or adds a new version to an existing secret.
@@ -70,22 +265,39 @@ func (s *Store) Create(vaultID, name, value string) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	vault := s.getOrCreateVault(vaultID)
-	sec, ok := vault[name]
-	if !ok {
-		key := make([]byte, 32)
-		if _, err := rand.Read(key); err != nil {
+	vault, err := s.getOrCreateVault(vaultID)
+	if err != nil {
+		return 0, err
+	}
+	sec, ok := vault.secrets[name]
+	var dataKey []byte
+	if ok {
+		dataKey, err = open(s.masterKey, sec.key, keyAAD(vaultID, name))
+		if err != nil {
+			return 0, fmt.Errorf("failed to unwrap data key of secret %q: %w", name, err)
+		}
+	} else {
+		dataKey = make([]byte, 32)
+		if _, err := rand.Read(dataKey); err != nil {
 			return 0, fmt.Errorf("failed to generate encryption key: %w", err)
 		}
+		wrapped, err := seal(s.masterKey, dataKey, keyAAD(vaultID, name))
+		if err != nil {
+			return 0, fmt.Errorf("failed to wrap data key: %w", err)
+		}
 		sec = &secret{
 			name:     name,
 			versions: make(map[int][]byte),
-			key:      key,
+			key:      wrapped,
 		}
-		vault[name] = sec
 	}
+	encrypted, err := seal(dataKey, []byte(value), valueAAD(name, sec.latest+1))
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+	vault.secrets[name] = sec
 	sec.latest++
-	sec.versions[sec.latest] = xorEncrypt([]byte(value), sec.key)
+	sec.versions[sec.latest] = encrypted
 	return sec.latest, nil
 }
 
@@ -95,22 +307,30 @@ func (s *Store) Unveil(vaultID, name string, version int) (string, int, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	vault, ok := s.vaults[vaultID]
-	if !ok {
-		return "", 0, fmt.Errorf("secret %q not found", name)
+	vault, err := s.getVault(vaultID)
+	if err != nil {
+		return "", 0, err
 	}
-	sec, ok := vault[name]
+	sec, ok := vault.secrets[name]
 	if !ok {
-		return "", 0, fmt.Errorf("secret %q not found", name)
+		return "", 0, fmt.Errorf("secret %q %w", name, ErrNotFound)
 	}
 	if version == 0 {
 		version = sec.latest
 	}
 	encrypted, ok := sec.versions[version]
 	if !ok {
-		return "", 0, fmt.Errorf("secret %q version %d not found", name, version)
+		return "", 0, fmt.Errorf("secret %q version %d %w", name, version, ErrNotFound)
+	}
+	dataKey, err := open(s.masterKey, sec.key, keyAAD(vaultID, name))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to unwrap data key of secret %q: %w", name, err)
+	}
+	value, err := open(dataKey, encrypted, valueAAD(name, version))
+	if err != nil {
+		return "", 0, fmt.Errorf("secret %q version %d: %w", name, version, err)
 	}
-	return string(xorEncrypt(encrypted, sec.key)), version, nil
+	return string(value), version, nil
 }
 
 // Delete removes a secret from a vault.
@@ -118,22 +338,121 @@ func (s *Store) Delete(vaultID, name string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	vault, ok := s.vaults[vaultID]
-	if !ok {
-		return fmt.Errorf("secret %q not found", name)
+	vault, err := s.getVault(vaultID)
+	if err != nil {
+		return err
 	}
-	if _, ok := vault[name]; !ok {
-		return fmt.Errorf("secret %q not found", name)
+	if _, ok := vault.secrets[name]; !ok {
+		return fmt.Errorf("secret %q %w", name, ErrNotFound)
 	}
-	delete(vault, name)
+	delete(vault.secrets, name)
 	return nil
 }
 
-// xorEncrypt XORs data with key (repeating key as needed).
-func xorEncrypt(data, key []byte) []byte {
-	result := make([]byte, len(data))
-	for i, b := range data {
-		result[i] = b ^ key[i%len(key)]
+// Snapshot is the serialized form of a Store.
+// Secret values are kept encrypted, exactly as they are held in memory.
+// The master key is not included; KeyCheck is used to verify it on restore.
+type Snapshot struct {
+	NextID   int64           `json:"NextID"`
+	KeyCheck []byte          `json:"KeyCheck"`
+	Vaults   []SnapshotVault `json:"Vaults"`
+}
+
+// SnapshotVault is a vault in a Snapshot.
+type SnapshotVault struct {
+	VaultMeta
+	Secrets []SnapshotSecret `json:"Secrets"`
+}
+
+// SnapshotSecret is a secret in a Snapshot.
+// Key is the wrapped data key and Versions are the encrypted values.
+type SnapshotSecret struct {
+	Name          string         `json:"Name"`
+	LatestVersion int            `json:"LatestVersion"`
+	Key           []byte         `json:"Key"`
+	Versions      map[int][]byte `json:"Versions"`
+}
+
+// Snapshot returns a deep copy of the store contents.
+func (s *Store) Snapshot() *Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keyCheck, err := seal(s.masterKey, keyCheckPlaintext, nil)
+	if err != nil {
+		panic(err) // only fails when the system random source fails
 	}
-	return result
+	st := &Snapshot{
+		NextID:   s.nextID,
+		KeyCheck: keyCheck,
+		Vaults:   make([]SnapshotVault, 0, len(s.vaults)),
+	}
+	for _, v := range s.vaults {
+		vs := SnapshotVault{
+			VaultMeta: v.meta.clone(),
+			Secrets:   make([]SnapshotSecret, 0, len(v.secrets)),
+		}
+		for _, sec := range v.secrets {
+			ss := SnapshotSecret{
+				Name:          sec.name,
+				LatestVersion: sec.latest,
+				Key:           bytes.Clone(sec.key),
+				Versions:      make(map[int][]byte, len(sec.versions)),
+			}
+			for ver, enc := range sec.versions {
+				ss.Versions[ver] = bytes.Clone(enc)
+			}
+			vs.Secrets = append(vs.Secrets, ss)
+		}
+		sort.Slice(vs.Secrets, func(i, j int) bool {
+			return vs.Secrets[i].Name < vs.Secrets[j].Name
+		})
+		st.Vaults = append(st.Vaults, vs)
+	}
+	sort.Slice(st.Vaults, func(i, j int) bool {
+		return st.Vaults[i].ID < st.Vaults[j].ID
+	})
+	return st
+}
+
+// Restore replaces the store contents with st.
+// It fails if st was encrypted with a different master key.
+func (s *Store) Restore(st *Snapshot) error {
+	if len(st.KeyCheck) == 0 {
+		if len(st.Vaults) > 0 {
+			return errors.New("data has no master key check; it was written by an older version of localserver")
+		}
+	} else if _, err := open(s.masterKey, st.KeyCheck, nil); err != nil {
+		return fmt.Errorf("master key does not match the data: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.vaults = make(map[string]*vault, len(st.Vaults))
+	if st.NextID > s.nextID {
+		s.nextID = st.NextID
+	}
+	for _, vs := range st.Vaults {
+		v := newVault(vs.VaultMeta.clone())
+		for _, ss := range vs.Secrets {
+			sec := &secret{
+				name:     ss.Name,
+				versions: make(map[int][]byte, len(ss.Versions)),
+				latest:   ss.LatestVersion,
+				key:      bytes.Clone(ss.Key),
+			}
+			for ver, enc := range ss.Versions {
+				sec.versions[ver] = bytes.Clone(enc)
+			}
+			v.secrets[ss.Name] = sec
+		}
+		s.vaults[vs.ID] = v
+	}
+	return nil
+}
+
+func (m VaultMeta) clone() VaultMeta {
+	m.Tags = append([]string{}, m.Tags...)
+	return m
 }
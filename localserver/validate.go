@@ -0,0 +1,197 @@
+package localserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+)
+
+// OpenAPISpec is the OpenAPI specification of the SecretManager API implemented by the server.
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
+
+// apiError is an error response in the format of the SAKURA Cloud API.
+type apiError struct {
+	IsFatal   bool   `json:"is_fatal"`
+	Serial    string `json:"serial"`
+	Status    string `json:"status"`
+	ErrorCode string `json:"error_code"`
+	ErrorMsg  string `json:"error_msg"`
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, msg string) {
+	serial := make([]byte, 16)
+	rand.Read(serial)
+	writeJSON(w, status, apiError{
+		IsFatal:   true,
+		Serial:    hex.EncodeToString(serial),
+		Status:    strconv.Itoa(status) + " " + http.StatusText(status),
+		ErrorCode: code,
+		ErrorMsg:  msg,
+	})
+}
+
+// validator validates requests and responses against OpenAPISpec.
+type validator struct {
+	routes    map[string]*routers.Route // operationId -> route
+	responses bool
+}
+
+func newValidator(responses bool) (*validator, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(OpenAPISpec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OpenAPI spec: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI spec: %w", err)
+	}
+	v := &validator{
+		routes:    make(map[string]*routers.Route),
+		responses: responses,
+	}
+	for path, item := range doc.Paths.Map() {
+		for method, op := range item.Operations() {
+			v.routes[op.OperationID] = &routers.Route{
+				Spec:      doc,
+				Path:      path,
+				PathItem:  item,
+				Method:    method,
+				Operation: op,
+			}
+		}
+	}
+	return v, nil
+}
+
+func (v *validator) options() *openapi3filter.Options {
+	return &openapi3filter.Options{
+		// Authentication is handled by Server.authenticate.
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		// Clients may send read-only fields (e.g. ID of CreateVault), which the API ignores.
+		ExcludeReadOnlyValidations: true,
+		MultiError:                 true,
+	}
+}
+
+// wrap returns a handler that validates requests to h, and responses of h if enabled.
+func (v *validator) wrap(operation string, h http.HandlerFunc) http.HandlerFunc {
+	route, ok := v.routes[operation]
+	if !ok {
+		panic(fmt.Sprintf("operation %s is not found in OpenAPI spec", operation))
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		// All path parameters of the spec are registered as {vault_id} in the server.
+		params := make(map[string]string)
+		for _, p := range route.Operation.Parameters {
+			if p.Value != nil && p.Value.In == openapi3.ParameterInPath {
+				params[p.Value.Name] = r.PathValue("vault_id")
+			}
+		}
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: params,
+			Route:      route,
+			Options:    v.options(),
+		}
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "bad_request", validationErrorMessage(err))
+			return
+		}
+		if !v.responses {
+			h(w, r)
+			return
+		}
+
+		rec := &bufferedWriter{header: make(http.Header)}
+		h(rec, r)
+		status := rec.statusCode()
+		err := openapi3filter.ValidateResponse(r.Context(), (&openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 status,
+			Header:                 rec.header,
+			Options:                v.options(),
+		}).SetBodyBytes(rec.body.Bytes()))
+		if err != nil {
+			msg := fmt.Sprintf("response of %s does not conform to the OpenAPI spec: %s", operation, validationErrorMessage(err))
+			log.Print(msg)
+			writeAPIError(w, http.StatusInternalServerError, "response_validation_failed", msg)
+			return
+		}
+		for k, vs := range rec.header {
+			w.Header()[k] = vs
+		}
+		w.WriteHeader(status)
+		w.Write(rec.body.Bytes())
+	}
+}
+
+func validationErrorMessage(err error) string {
+	if me, ok := err.(openapi3.MultiError); ok {
+		msgs := make([]string, 0, len(me))
+		for _, e := range me {
+			msgs = append(msgs, validationErrorMessage(e))
+		}
+		return strings.Join(msgs, "; ")
+	}
+	switch e := err.(type) {
+	case *openapi3filter.RequestError:
+		if e.Err != nil {
+			return validationErrorMessage(e.Err)
+		}
+		return e.Reason
+	case *openapi3filter.ResponseError:
+		if e.Err != nil {
+			return validationErrorMessage(e.Err)
+		}
+		return e.Reason
+	case *openapi3.SchemaError:
+		if path := e.JSONPointer(); len(path) > 0 {
+			return fmt.Sprintf("%s: %s", strings.Join(path, "."), e.Reason)
+		}
+		return e.Reason
+	}
+	return err.Error()
+}
+
+// bufferedWriter is an http.ResponseWriter that holds the response for validation.
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *bufferedWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
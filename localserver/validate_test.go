@@ -0,0 +1,120 @@
+package localserver_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
+
+	"github.com/fujiwara/sakura-secrets-cli/localserver"
+)
+
+func TestValidationConforms(t *testing.T) {
+	srv := httptest.NewServer(localserver.NewServer(testPrefix, localserver.WithValidation(true)))
+	defer srv.Close()
+	ctx := t.Context()
+
+	// Every operation succeeds with validation of requests and responses
+	vaultOp := newTestVaultOp(t, srv.URL)
+	vault, err := vaultOp.Create(ctx, v1.CreateVault{Name: "v", KmsKeyID: "kms-1", Tags: []string{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vaultOp.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vaultOp.Read(ctx, vault.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vaultOp.Update(ctx, vault.ID, v1.Vault{Name: "v2", Description: v1.NewOptString("d")}); err != nil {
+		t.Fatal(err)
+	}
+
+	secOp := newTestSecretOp(t, srv.URL, vault.ID)
+	if _, err := secOp.Create(ctx, v1.CreateSecret{Name: "foo", Value: "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := secOp.List(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := secOp.Unveil(ctx, v1.Unveil{Name: "foo", Version: v1.NewOptNilInt(1)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := secOp.Delete(ctx, v1.DeleteSecret{Name: "foo"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vaultOp.Delete(ctx, vault.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidationRejects(t *testing.T) {
+	srv := httptest.NewServer(localserver.NewServer(testPrefix, localserver.WithValidation(false)))
+	defer srv.Close()
+	secretsURL := srv.URL + testPrefix + "/secretmanager/vaults/" + testVaultID + "/secrets"
+
+	tests := []struct {
+		name    string
+		body    string
+		wantMsg string
+	}{
+		{
+			name:    "name too long",
+			body:    `{"Secret":{"Name":"` + strings.Repeat("a", 256) + `","Value":"v"}}`,
+			wantMsg: "Name",
+		},
+		{
+			name:    "missing value",
+			body:    `{"Secret":{"Name":"foo"}}`,
+			wantMsg: "Value",
+		},
+		{
+			name:    "missing secret",
+			body:    `{}`,
+			wantMsg: "Secret",
+		},
+		{
+			name:    "wrong type",
+			body:    `{"Secret":{"Name":1,"Value":"v"}}`,
+			wantMsg: "Name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(secretsURL, "application/json", bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("unexpected status: %d", resp.StatusCode)
+			}
+			var e struct {
+				IsFatal   bool   `json:"is_fatal"`
+				Status    string `json:"status"`
+				ErrorCode string `json:"error_code"`
+				ErrorMsg  string `json:"error_msg"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+				t.Fatal(err)
+			}
+			if e.Status != "400 Bad Request" || e.ErrorCode != "bad_request" || !strings.Contains(e.ErrorMsg, tt.wantMsg) {
+				t.Errorf("unexpected error response: %+v", e)
+			}
+		})
+	}
+
+	// A valid request passes through
+	resp, err := http.Post(secretsURL, "application/json", bytes.NewBufferString(`{"Secret":{"Name":"foo","Value":"bar"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("unexpected status for valid request: %d", resp.StatusCode)
+	}
+}
@@ -2,8 +2,11 @@ package sscli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"strconv"
 
 	"github.com/alecthomas/kong"
 	"github.com/sacloud/saclient-go"
@@ -13,7 +16,17 @@ import (
 
 func Run(ctx context.Context) error {
 	c := &CLI{}
-	k, err := kong.New(c, kong.Vars{"version": fmt.Sprintf("sakura-secrets-cli %s", Version)})
+	k, err := kong.New(c, kong.Vars{
+		"version":                    fmt.Sprintf("sakura-secrets-cli %s", Version),
+		"default_export_config":      DefaultExportConfigFile,
+		"default_supervise":          strconv.FormatBool(defaultSupervise),
+		"default_export_concurrency": strconv.Itoa(DefaultExportConcurrency),
+		"default_api_timeout":        DefaultAPIOptions.Timeout.String(),
+		"default_api_deadline":       DefaultAPIOptions.Deadline.String(),
+		"default_api_max_retries":    strconv.Itoa(DefaultAPIOptions.MaxRetries),
+		"default_api_retry_wait_min": DefaultAPIOptions.RetryWaitMin.String(),
+		"default_api_retry_wait_max": DefaultAPIOptions.RetryWaitMax.String(),
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create kong: %w", err)
 	}
@@ -21,6 +34,9 @@ func Run(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse command line: %w", err)
 	}
+	if err := c.APIOptions.Validate(); err != nil {
+		return fmt.Errorf("invalid API options: %w", err)
+	}
 	switch kx.Command() {
 	case "secret list":
 		return runListCommand(ctx, c)
@@ -34,12 +50,52 @@ func Run(ctx context.Context) error {
 		return runDeleteCommand(ctx, c)
 	case "secret export", "secret export <commands>":
 		return runExportCommand(ctx, c)
+	case "secret render <template>":
+		return runRenderCommand(ctx, c)
+	case "inject", "inject <commands>":
+		return runInjectCommand(ctx, c)
+	case "vault list":
+		return runVaultListCommand(ctx, c)
+	case "vault get <id>":
+		return runVaultGetCommand(ctx, c)
+	case "vault create":
+		return runVaultCreateCommand(ctx, c)
+	case "vault update <id>":
+		return runVaultUpdateCommand(ctx, c)
+	case "vault delete <id>":
+		return runVaultDeleteCommand(ctx, c)
 	default:
 		return fmt.Errorf("unknown command: %s", kx.Command())
 	}
 }
 
-func newSMClient() (*v1.Client, error) {
+func newSMClient(opts APIOptions) (*v1.Client, error) {
+	// pageMiddleware adds paging parameters, which the generated client does not have.
+	// retryMiddleware retries only idempotent requests, instead of the retries of saclient
 	var sa saclient.Client
+	if err := sa.SetWith(saclient.WithoutRetry(), saclient.WithMiddleware(pageMiddleware, retryMiddleware(opts))); err != nil {
+		return nil, err
+	}
 	return sm.NewClient(&sa)
 }
+
+type pageFromKey struct{}
+
+// withPageFrom returns a context to request the page of a list operation starting at from.
+func withPageFrom(ctx context.Context, from int) context.Context {
+	return context.WithValue(ctx, pageFromKey{}, from)
+}
+
+// pageMiddleware is a middleware of saclient, which adds From to the query of the request from withPageFrom.
+func pageMiddleware(req *http.Request, pull func() (saclient.Middleware, bool)) (*http.Response, error) {
+	if from, ok := req.Context().Value(pageFromKey{}).(int); ok && from > 0 {
+		q := req.URL.Query()
+		q.Set("From", strconv.Itoa(from))
+		req.URL.RawQuery = q.Encode()
+	}
+	next, ok := pull()
+	if !ok {
+		return nil, errors.New("no middleware to send the request")
+	}
+	return next(req, pull)
+}
@@ -0,0 +1,228 @@
+package sscli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	apiclient "github.com/sacloud/api-client-go"
+	sm "github.com/sacloud/secretmanager-api-go"
+
+	"github.com/fujiwara/sakura-secrets-cli/internal/atomicfile"
+)
+
+type RenderCommand struct {
+	Template string `arg:"" help:"Template file, or directory of templates" type:"existingpath"`
+	Output   string `help:"Destination file, or destination directory for a directory of templates (default: stdout)" short:"o" type:"path"`
+	Missing  string `help:"How to handle missing secrets and keys, and unused secrets (error, zero)" enum:"error,zero" default:"error"`
+}
+
+// TemplateExt is removed from the names of rendered files of a directory of templates.
+const TemplateExt = ".tmpl"
+
+type secretVersionKey struct {
+	name    string
+	version int
+}
+
+// secretRenderer renders templates with functions to get secrets.
+// Each secret is fetched only once.
+type secretRenderer struct {
+	ctx         context.Context
+	secOp       sm.SecretAPI
+	missingZero bool
+	cache       map[secretVersionKey]string
+	used        map[string]bool // names of secrets referred to by templates
+}
+
+func (r *secretRenderer) funcs() template.FuncMap {
+	return template.FuncMap{
+		"secret": func(name string) (string, error) {
+			return r.secretVersion(name, 0)
+		},
+		"secretVersion": r.secretVersion,
+		"secretJSON":    r.secretJSON,
+	}
+}
+
+// lookup returns the value of the secret. version 0 means the latest version.
+// If the secret is not found and missing secrets are allowed, ok is false.
+func (r *secretRenderer) lookup(name string, version int) (value string, ok bool, err error) {
+	r.used[name] = true
+	key := secretVersionKey{name: name, version: version}
+	if v, ok := r.cache[key]; ok {
+		return v, true, nil
+	}
+	res, err := unveilSecret(r.ctx, r.secOp, name, version)
+	if err != nil {
+		if r.missingZero && apiclient.IsNotFoundError(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	r.cache[key] = res.Value
+	return res.Value, true, nil
+}
+
+func (r *secretRenderer) secretVersion(name string, version int) (string, error) {
+	v, _, err := r.lookup(name, version)
+	return v, err
+}
+
+// secretJSON returns the value of the key in the JSON object of the latest version of the secret.
+func (r *secretRenderer) secretJSON(name, key string) (string, error) {
+	s, ok, err := r.lookup(name, 0)
+	if err != nil || !ok {
+		return "", err
+	}
+	v, ok, err := jsonObjectValue(name, s, key)
+	if err != nil {
+		return "", err
+	}
+	if !ok && !r.missingZero {
+		return "", fmt.Errorf("key %s is not found in secret %s", key, name)
+	}
+	return v, nil
+}
+
+// jsonObjectValue returns the value of the key in the JSON object value of the secret name.
+// Values other than strings are returned as JSON.
+func jsonObjectValue(name, value, key string) (string, bool, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &m); err != nil {
+		return "", false, fmt.Errorf("failed to parse secret %s as JSON object: %w", name, err)
+	}
+	raw, ok := m[key]
+	if !ok {
+		return "", false, nil
+	}
+	var v string
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw), true, nil
+	}
+	return v, true, nil
+}
+
+func (r *secretRenderer) render(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	missingKey := "missingkey=error"
+	if r.missingZero {
+		missingKey = "missingkey=zero"
+	}
+	tmpl, err := template.New(filepath.Base(path)).Option(missingKey).Funcs(r.funcs()).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", path, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %w", path, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// checkUnused returns an error if some secrets in the vault are not referred to by the rendered templates.
+// Unused secrets are allowed when missing secrets are.
+func (r *secretRenderer) checkUnused() error {
+	if r.missingZero {
+		return nil
+	}
+	secrets, err := r.secOp.List(r.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list secrets: %w", err)
+	}
+	var unused []string
+	for _, s := range secrets {
+		if !r.used[s.Name] {
+			unused = append(unused, s.Name)
+		}
+	}
+	if len(unused) > 0 {
+		return fmt.Errorf("secrets not used by the templates: %s", strings.Join(unused, ", "))
+	}
+	return nil
+}
+
+func runRenderCommand(ctx context.Context, cli *CLI) error {
+	cmd := cli.Secret.Render
+	ctx, cancel := cli.APIOptions.withDeadline(ctx)
+	defer cancel()
+	client, err := newSMClient(cli.APIOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create SecretManager client: %w", err)
+	}
+	r := &secretRenderer{
+		ctx:         ctx,
+		secOp:       sm.NewSecretOp(client, cli.Secret.VaultID),
+		missingZero: cmd.Missing == "zero",
+		cache:       make(map[secretVersionKey]string),
+		used:        make(map[string]bool),
+	}
+
+	st, err := os.Stat(cmd.Template)
+	if err != nil {
+		return err
+	}
+	if !st.IsDir() {
+		b, err := r.render(cmd.Template)
+		if err != nil {
+			return err
+		}
+		if err := r.checkUnused(); err != nil {
+			return err
+		}
+		if cmd.Output == "" {
+			_, err := os.Stdout.Write(b)
+			return err
+		}
+		if err := atomicfile.WriteFile(cmd.Output, b, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", cmd.Output, err)
+		}
+		return nil
+	}
+
+	if cmd.Output == "" {
+		return fmt.Errorf("--output directory is required to render a directory of templates")
+	}
+	// render all templates before writing any files, not to leave partial results on errors
+	rendered := make(map[string][]byte)
+	err = filepath.WalkDir(cmd.Template, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(cmd.Template, path)
+		if err != nil {
+			return err
+		}
+		b, err := r.render(path)
+		if err != nil {
+			return err
+		}
+		rendered[strings.TrimSuffix(rel, TemplateExt)] = b
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := r.checkUnused(); err != nil {
+		return err
+	}
+	for rel, b := range rendered {
+		dest := filepath.Join(cmd.Output, rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", dest, err)
+		}
+		if err := atomicfile.WriteFile(dest, b, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", dest, err)
+		}
+	}
+	return nil
+}
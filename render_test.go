@@ -0,0 +1,136 @@
+package sscli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
+)
+
+const testVaultID = "test-vault"
+
+// createTestSecrets creates secrets in testVaultID. Each value is a new version.
+func createTestSecrets(t *testing.T, secrets map[string][]string) {
+	t.Helper()
+	client, err := newSMClient(DefaultAPIOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range secrets {
+		for _, value := range values {
+			_, err := client.SecretmanagerVaultsSecretsCreate(t.Context(), &v1.WrappedCreateSecret{
+				Secret: v1.CreateSecret{Name: name, Value: value},
+			}, v1.SecretmanagerVaultsSecretsCreateParams{VaultResourceID: testVaultID})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	newTestServer(t)
+	createTestSecrets(t, map[string][]string{
+		"password": {"v1", "v2"},
+		"db":       {`{"host":"localhost","port":5432}`},
+	})
+	dir := t.TempDir()
+	tmplDir := filepath.Join(dir, "templates")
+	if err := os.MkdirAll(filepath.Join(tmplDir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"app.conf.tmpl": `password={{ secret "password" }} old={{ secretVersion "password" 1 }}`,
+		"sub/db.conf":   `{{ secretJSON "db" "host" }}:{{ secretJSON "db" "port" }}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmplDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cli := &CLI{}
+	cli.Secret.VaultID = testVaultID
+	cli.Secret.Render = RenderCommand{Template: tmplDir, Output: filepath.Join(dir, "out"), Missing: "error"}
+	if err := runRenderCommand(t.Context(), cli); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"app.conf":    "password=v2 old=v1",
+		"sub/db.conf": "localhost:5432",
+	}
+	for name, content := range want {
+		path := filepath.Join(dir, "out", name)
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Errorf("%s: got %q, want %q", name, b, content)
+		}
+		st, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Mode().Perm() != 0600 {
+			t.Errorf("%s: unexpected mode %v", name, st.Mode())
+		}
+	}
+}
+
+func TestRenderMissing(t *testing.T) {
+	newTestServer(t)
+	createTestSecrets(t, map[string][]string{
+		"db": {`{"host":"localhost"}`},
+	})
+	dir := t.TempDir()
+	tests := []struct {
+		template string
+		missing  string
+		want     string
+		wantErr  bool
+	}{
+		{template: `[{{ secret "nothing" }}]`, missing: "error", wantErr: true},
+		{template: `[{{ secret "nothing" }}]`, missing: "zero", want: "[]"},
+		{template: `[{{ secretJSON "db" "user" }}]`, missing: "error", wantErr: true},
+		{template: `[{{ secretJSON "db" "user" }}]`, missing: "zero", want: "[]"},
+		{template: `[{{ secretJSON "nothing" "user" }}]`, missing: "zero", want: "[]"},
+		{template: `[{{ secretJSON "db" "host" }}]`, missing: "error", want: "[localhost]"},
+		{template: `[]`, missing: "error", wantErr: true}, // db is unused
+		{template: `[]`, missing: "zero", want: "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template+" "+tt.missing, func(t *testing.T) {
+			tmpl := filepath.Join(dir, "template")
+			out := filepath.Join(dir, "out")
+			os.Remove(out)
+			if err := os.WriteFile(tmpl, []byte(tt.template), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cli := &CLI{}
+			cli.Secret.VaultID = testVaultID
+			cli.Secret.Render = RenderCommand{Template: tmpl, Output: out, Missing: tt.missing}
+			err := runRenderCommand(t.Context(), cli)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if _, err := os.Stat(out); !os.IsNotExist(err) {
+					t.Error("output is written on error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("got %q, want %q", b, tt.want)
+			}
+		})
+	}
+}
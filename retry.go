@@ -0,0 +1,240 @@
+package sscli
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sacloud/saclient-go"
+)
+
+// APIOptions are options of API requests.
+type APIOptions struct {
+	Timeout      time.Duration `help:"Timeout of each API request" default:"${default_api_timeout}" env:"SAKURA_SECRETS_TIMEOUT"`
+	Deadline     time.Duration `help:"Deadline of all API requests of the command including retries (of each poll with --watch-interval). 0 means no deadline" default:"${default_api_deadline}" env:"SAKURA_SECRETS_DEADLINE"`
+	MaxRetries   int           `help:"Max retries of idempotent API requests on transient errors" default:"${default_api_max_retries}" env:"SAKURA_SECRETS_MAX_RETRIES"`
+	RetryWaitMin time.Duration `help:"Wait before the first retry, doubled for each retry" default:"${default_api_retry_wait_min}" env:"SAKURA_SECRETS_RETRY_WAIT_MIN"`
+	RetryWaitMax time.Duration `help:"Max wait between retries" default:"${default_api_retry_wait_max}" env:"SAKURA_SECRETS_RETRY_WAIT_MAX"`
+}
+
+// DefaultAPIOptions are the default options of API requests.
+var DefaultAPIOptions = APIOptions{
+	Timeout:      30 * time.Second,
+	Deadline:     2 * time.Minute,
+	MaxRetries:   4,
+	RetryWaitMin: 500 * time.Millisecond,
+	RetryWaitMax: 10 * time.Second,
+}
+
+// Validate validates the options.
+func (o APIOptions) Validate() error {
+	switch {
+	case o.Timeout < 0, o.Deadline < 0:
+		return errors.New("timeout and deadline must not be negative")
+	case o.MaxRetries < 0:
+		return errors.New("max retries must not be negative")
+	case o.RetryWaitMin < 0 || o.RetryWaitMax < o.RetryWaitMin:
+		return errors.New("retry wait max must not be less than retry wait min")
+	}
+	return nil
+}
+
+// withDeadline returns ctx with the deadline, if any.
+// Commands call it after reading local input like confirmations, just before API requests.
+func (o APIOptions) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.Deadline > 0 {
+		return context.WithTimeout(ctx, o.Deadline)
+	}
+	return context.WithCancel(ctx)
+}
+
+// retryDoer retries idempotent requests on transient errors, with exponential backoff honoring Retry-After.
+// It gives up at the deadline of the request context, which is set by APIOptions.withDeadline.
+type retryDoer struct {
+	client interface {
+		Do(*http.Request) (*http.Response, error)
+	}
+	opts APIOptions
+}
+
+func (d retryDoer) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	retryable := isIdempotentRequest(req)
+	for attempt := 0; ; attempt++ {
+		res, err := d.attempt(ctx, req, attempt)
+		if !retryable || attempt >= d.opts.MaxRetries || ctx.Err() != nil || !isRetryableResponse(res, err) {
+			return res, err
+		}
+		wait := retryWait(d.opts, attempt, res, rand.Float64())
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			// give up early with the last result, not to fail with the deadline after waiting
+			return res, err
+		}
+		args := []any{"method", req.Method, "path", req.URL.Path, "wait", wait}
+		if err != nil {
+			args = append(args, "error", err)
+		} else {
+			args = append(args, "status", res.StatusCode)
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+		slog.Warn("retrying API request", args...)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryMiddleware returns a middleware of saclient, which sends the request through the rest of the middlewares with retryDoer.
+// Middlewares are chained by pulling the next one, so the rest are collected to be replayed on each attempt.
+func retryMiddleware(opts APIOptions) saclient.Middleware {
+	return func(req *http.Request, pull func() (saclient.Middleware, bool)) (*http.Response, error) {
+		var rest middlewareDoer
+		for m, ok := pull(); ok; m, ok = pull() {
+			rest = append(rest, m)
+		}
+		return retryDoer{client: rest, opts: opts}.Do(req)
+	}
+}
+
+// middlewareDoer sends a request through the chain of middlewares.
+type middlewareDoer []saclient.Middleware
+
+func (d middlewareDoer) Do(req *http.Request) (*http.Response, error) {
+	i := 0
+	pull := func() (saclient.Middleware, bool) {
+		if i >= len(d) {
+			return nil, false
+		}
+		i++
+		return d[i-1], true
+	}
+	m, ok := pull()
+	if !ok {
+		return nil, errors.New("no middleware to send the request")
+	}
+	return m(req, pull)
+}
+
+// attempt sends the request once with the timeout. The timeout is released when the response body is closed.
+func (d retryDoer) attempt(ctx context.Context, req *http.Request, attempt int) (*http.Response, error) {
+	cancel := context.CancelFunc(func() {})
+	if d.opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, d.opts.Timeout)
+	}
+	r := req.Clone(ctx)
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		r.Body = body
+	}
+	res, err := d.client.Do(r)
+	return withCancel(res, err, cancel)
+}
+
+// withCancel returns res with cancel called when its body is closed, or calls cancel on errors.
+func withCancel(res *http.Response, err error, cancel context.CancelFunc) (*http.Response, error) {
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// isIdempotentRequest reports whether the request can be sent again.
+// Unveiling a secret is a POST request, but it does not change anything.
+func isIdempotentRequest(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return strings.HasSuffix(req.URL.Path, "/secrets/unveil")
+	default:
+		return false
+	}
+}
+
+// isRetryableResponse reports whether the error or the status code of the response is transient.
+func isRetryableResponse(res *http.Response, err error) bool {
+	if err != nil {
+		var urlErr *url.Error
+		var certErr *tls.CertificateVerificationError
+		// errors before sending requests, like missing credentials, are not transient
+		return errors.As(err, &urlErr) && !errors.As(err, &certErr)
+	}
+	switch res.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryWait returns the wait before the retry after the attempt (0-based).
+// The backoff is doubled for each retry up to RetryWaitMax, and jittered to [50%, 100%) with rnd in [0, 1).
+// Retry-After of the response is used as is if it is longer.
+func retryWait(opts APIOptions, attempt int, res *http.Response, rnd float64) time.Duration {
+	wait := opts.RetryWaitMin
+	for range attempt {
+		if wait >= opts.RetryWaitMax {
+			break
+		}
+		wait *= 2
+	}
+	wait = time.Duration(float64(min(wait, opts.RetryWaitMax)) * (0.5 + rnd/2))
+	if res != nil {
+		if after, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+			wait = max(wait, after)
+		}
+	}
+	return wait
+}
+
+// parseRetryAfter parses Retry-After in seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(v); err == nil {
+		if sec < 0 {
+			return 0, false
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
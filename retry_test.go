@@ -0,0 +1,262 @@
+package sscli
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetryOptions = APIOptions{
+	Timeout:      time.Second,
+	MaxRetries:   3,
+	RetryWaitMin: time.Millisecond,
+	RetryWaitMax: 10 * time.Millisecond,
+}
+
+// newFlakyServer returns a server that responds with the statuses in order, and then 200.
+// It fails the test if the request body differs from want.
+func newFlakyServer(t *testing.T, want string, statuses ...int) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var count atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := count.Add(1)
+		b, _ := io.ReadAll(r.Body)
+		if string(b) != want {
+			t.Errorf("unexpected body of attempt %d: %q", n, b)
+		}
+		if int(n) <= len(statuses) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &count
+}
+
+func TestRetryDoer(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		statuses []int
+		status   int
+		attempts int64
+	}{
+		{"unveil is retried", http.MethodPost, "/vaults/v/secrets/unveil", []int{503, 502}, 200, 3},
+		{"get is retried", http.MethodGet, "/vaults/v/secrets", []int{429}, 200, 2},
+		{"create is not retried", http.MethodPost, "/vaults/v/secrets", []int{503}, 503, 1},
+		{"client errors are not retried", http.MethodPost, "/vaults/v/secrets/unveil", []int{400}, 400, 1},
+		{"give up after max retries", http.MethodPut, "/vaults/v", []int{500, 500, 500, 500, 500}, 500, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"Secret":{"Name":"foo"}}`
+			srv, count := newFlakyServer(t, body, tt.statuses...)
+			req, err := http.NewRequestWithContext(t.Context(), tt.method, srv.URL+tt.path, strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := retryDoer{client: srv.Client(), opts: testRetryOptions}.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+			if res.StatusCode != tt.status {
+				t.Errorf("unexpected status: %d", res.StatusCode)
+			}
+			if n := count.Load(); n != tt.attempts {
+				t.Errorf("unexpected attempts: %d", n)
+			}
+		})
+	}
+}
+
+func TestRetryDoerTimeout(t *testing.T) {
+	var count atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	opts := testRetryOptions
+	opts.Timeout = 100 * time.Millisecond
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := retryDoer{client: srv.Client(), opts: opts}.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	// the body is readable after Do returns
+	b, err := io.ReadAll(res.Body)
+	if err != nil || string(b) != "ok" {
+		t.Errorf("unexpected body: %q, %v", b, err)
+	}
+	if n := count.Load(); n != 2 {
+		t.Errorf("unexpected attempts: %d", n)
+	}
+}
+
+func TestRetryDoerDeadline(t *testing.T) {
+	var count atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count.Add(1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	res, err := retryDoer{client: srv.Client(), opts: testRetryOptions}.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	// Retry-After exceeds the deadline, so it gives up without waiting
+	if res.StatusCode != http.StatusServiceUnavailable || count.Load() != 1 {
+		t.Errorf("unexpected result: status %d, attempts %d", res.StatusCode, count.Load())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waited too long: %s", elapsed)
+	}
+}
+
+func TestDeadlineAfterStdin(t *testing.T) {
+	newTestServer(t)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() { os.Stdin = stdin })
+	go func() {
+		// input slower than the deadline
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "value")
+		w.Close()
+	}()
+
+	cli := &CLI{APIOptions: testRetryOptions}
+	cli.APIOptions.Deadline = 100 * time.Millisecond
+	cli.Secret.VaultID = testVaultID
+	cli.Secret.Create = CreateCommand{Name: "api_key", Stdin: true}
+	lines := captureStdout(t, func() error { return runCreateCommand(t.Context(), cli) })
+	if len(lines) != 1 || !strings.Contains(lines[0], `"Name":"api_key"`) {
+		t.Errorf("unexpected output: %v", lines)
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	opts := APIOptions{RetryWaitMin: time.Second, RetryWaitMax: 10 * time.Second}
+	tests := []struct {
+		attempt    int
+		retryAfter string
+		rnd        float64
+		want       time.Duration
+	}{
+		{0, "", 0.999999999, time.Second},
+		{0, "", 0, 500 * time.Millisecond},
+		{2, "", 0.999999999, 4 * time.Second},
+		{10, "", 0.999999999, 10 * time.Second},
+		{100, "", 0, 5 * time.Second},
+		{0, "30", 0, 30 * time.Second},
+		{3, "1", 0, 4 * time.Second},
+		{0, "invalid", 0, 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		res := &http.Response{Header: http.Header{}}
+		if tt.retryAfter != "" {
+			res.Header.Set("Retry-After", tt.retryAfter)
+		}
+		got := retryWait(opts, tt.attempt, res, tt.rnd).Round(time.Millisecond)
+		if got != tt.want {
+			t.Errorf("retryWait(%d, %q, %v) = %s, want %s", tt.attempt, tt.retryAfter, tt.rnd, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 2, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{"Wed, 05 Feb 2025 12:00:30 GMT", 30 * time.Second, true},
+		{"Wed, 05 Feb 2025 11:00:00 GMT", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestAPIOptionsValidate(t *testing.T) {
+	if err := DefaultAPIOptions.Validate(); err != nil {
+		t.Error(err)
+	}
+	invalid := []APIOptions{
+		{Timeout: -1},
+		{MaxRetries: -1},
+		{RetryWaitMin: 2 * time.Second, RetryWaitMax: time.Second},
+	}
+	for _, o := range invalid {
+		if err := o.Validate(); err == nil {
+			t.Errorf("expected error for %+v", o)
+		}
+	}
+}
+
+func TestExportSecretsRetry(t *testing.T) {
+	var failed atomic.Bool
+	newTestServer(t, func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// fail the first unveil request
+			if strings.HasSuffix(r.URL.Path, "/secrets/unveil") && !failed.Swap(true) {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	})
+	createTestSecrets(t, map[string][]string{"api_key": {"key1"}})
+
+	envs, err := ExportSecrets(t.Context(), testVaultID, []ExportSecret{{Name: "api_key"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !failed.Load() || envs["API_KEY"] != "key1" {
+		t.Errorf("unexpected result: failed=%v, envs=%v", failed.Load(), envs)
+	}
+}
@@ -0,0 +1,161 @@
+package sscli
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/fujiwara/sakura-secrets-cli/internal/atomicfile"
+)
+
+// FilesOptions are options of secret export to pass the secrets to the command in files.
+type FilesOptions struct {
+	Files    bool   `help:"Pass the secrets to the command in files of a private directory instead of environment variables. The files are removed when the command exits"`
+	FilesDir string `help:"Directory to create the private directory in (default: $XDG_RUNTIME_DIR or the temporary directory)" type:"path" placeholder:"DIR"`
+	FilesEnv string `help:"Environment variable to pass the path of the private directory to the command" default:"CREDENTIALS_DIRECTORY"`
+}
+
+// secretFiles is a private directory (0700) with a file (0400) per environment variable,
+// named as the variable like systemd credentials.
+type secretFiles struct {
+	dir string
+}
+
+// newSecretFiles creates a private directory in parent.
+// On systems with systemd, $XDG_RUNTIME_DIR is a tmpfs not to write the secrets to disks.
+func newSecretFiles(parent string) (*secretFiles, error) {
+	parent = cmp.Or(parent, os.Getenv("XDG_RUNTIME_DIR"), os.TempDir())
+	dir, err := os.MkdirTemp(parent, "sakura-secrets-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a directory for secrets: %w", err)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		os.Remove(dir)
+		return nil, fmt.Errorf("failed to create a directory for secrets: %w", err)
+	}
+	return &secretFiles{dir: dir}, nil
+}
+
+// write writes envs to the files, and shreds the files of variables not in envs anymore.
+func (f *secretFiles) write(envs map[string]string) error {
+	for k, v := range envs {
+		path := filepath.Join(f.dir, k)
+		// the old content is shredded after replaced, not to leave it in the unlinked file
+		old, err := openToShred(path)
+		if err != nil {
+			return err
+		}
+		err = atomicfile.WriteFile(path, []byte(v), 0400)
+		if old != nil {
+			err = errors.Join(err, shred(old))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write secret file %s: %w", path, err)
+		}
+	}
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if _, ok := envs[e.Name()]; !ok {
+			if err := shredFile(filepath.Join(f.dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// remove shreds all files and removes the directory.
+func (f *secretFiles) remove() error {
+	if err := f.write(nil); err != nil {
+		return err
+	}
+	return os.Remove(f.dir)
+}
+
+// openToShred opens the file to overwrite, or returns nil if it does not exist.
+func openToShred(path string) (*os.File, error) {
+	if err := os.Chmod(path, 0600); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_WRONLY, 0)
+}
+
+// shred overwrites the content of the file with zeros, and closes it.
+// It does not guarantee that no copies remain on copy-on-write filesystems or SSDs,
+// so the directory should be on a tmpfs.
+func shred(file *os.File) error {
+	defer file.Close()
+	st, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(file, zeroReader{}, st.Size()); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// shredFile shreds and removes the file.
+func shredFile(path string) error {
+	file, err := openToShred(path)
+	if err != nil || file == nil {
+		return err
+	}
+	if err := shred(file); err != nil {
+		return fmt.Errorf("failed to shred %s: %w", path, err)
+	}
+	return os.Remove(path)
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// runCommandWithSecretFiles runs the command supervised with envs in files,
+// and removes the files after the command exits.
+func runCommandWithSecretFiles(ctx context.Context, opt FilesOptions, envs map[string]string, command []string) error {
+	files, err := newSecretFiles(opt.FilesDir)
+	if err != nil {
+		return err
+	}
+	defer files.removeLogged()
+	if err := files.write(envs); err != nil {
+		return err
+	}
+	// the process must remain to remove the files, so the command cannot replace it
+	return runCommandWithEnvs(ctx, RunOptions{Supervise: true}, []string{opt.FilesEnv + "=" + files.dir}, command)
+}
+
+func (f *secretFiles) removeLogged() {
+	if err := f.remove(); err != nil {
+		slog.Warn("failed to remove secret files", "dir", f.dir, "error", err)
+	}
+}
+
+// validate validates the options with the command to run.
+func (opt FilesOptions) validate(command []string) error {
+	if !opt.Files {
+		return nil
+	}
+	if len(command) == 0 {
+		return fmt.Errorf("--files requires a command")
+	}
+	if opt.FilesEnv == "" || EnvKeyInvalidRegex.MatchString(opt.FilesEnv) {
+		return fmt.Errorf("invalid environment variable name %q for --files-env", opt.FilesEnv)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package sscli
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// openSecretFile opens the secret file to read its content later, even after it is replaced or removed.
+func openSecretFile(t *testing.T, files *secretFiles, name string) *os.File {
+	t.Helper()
+	f, err := os.Open(filepath.Join(files.dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// assertShredded checks that the content of the opened file is overwritten with zeros.
+func assertShredded(t *testing.T, f *os.File, size int) {
+	t.Helper()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != strings.Repeat("\x00", size) {
+		t.Errorf("%s is not shredded: %q", f.Name(), b)
+	}
+}
+
+func assertMode(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		return
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode().Perm() != want {
+		t.Errorf("unexpected mode of %s: %s", path, st.Mode())
+	}
+}
+
+func TestSecretFilesWrite(t *testing.T) {
+	files, err := newSecretFiles(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(files.removeLogged)
+	if err := files.write(map[string]string{"API_KEY": "key1", "DB_PASSWORD": "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	assertMode(t, files.dir, 0700)
+	assertMode(t, filepath.Join(files.dir, "API_KEY"), 0400)
+	assertMode(t, filepath.Join(files.dir, "DB_PASSWORD"), 0400)
+
+	// rewrite with changes
+	oldKey := openSecretFile(t, files, "API_KEY")
+	oldPassword := openSecretFile(t, files, "DB_PASSWORD")
+	if err := files.write(map[string]string{"API_KEY": "key2"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(files.dir, "API_KEY"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "key2" {
+		t.Errorf("unexpected content: %q", b)
+	}
+	assertMode(t, filepath.Join(files.dir, "API_KEY"), 0400)
+	if runtime.GOOS != "windows" {
+		// the replaced and the removed files are shredded
+		assertShredded(t, oldKey, len("key1"))
+		assertShredded(t, oldPassword, len("secret"))
+	}
+	entries, err := os.ReadDir(files.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "API_KEY" {
+		t.Errorf("unexpected files remain: %v", entries)
+	}
+}
+
+func TestSecretFilesRemove(t *testing.T) {
+	files, err := newSecretFiles(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := files.write(map[string]string{"API_KEY": "key1", "DB_PASSWORD": "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	old := openSecretFile(t, files, "DB_PASSWORD")
+	if err := files.remove(); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		assertShredded(t, old, len("secret"))
+	}
+	if _, err := os.Stat(files.dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("directory is not removed: %v", err)
+	}
+}
+
+func TestRunCommandWithSecretFiles(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	tmp := t.TempDir()
+	out := filepath.Join(tmp, "out")
+	t.Setenv("OUT", out)
+	opt := FilesOptions{Files: true, FilesDir: tmp, FilesEnv: "CREDENTIALS_DIRECTORY"}
+	script := `echo "$CREDENTIALS_DIRECTORY" > "$OUT.dir"; cat "$CREDENTIALS_DIRECTORY/API_KEY" > "$OUT"; [ -z "$API_KEY" ]`
+	err := runCommandWithSecretFiles(t.Context(), opt, map[string]string{"API_KEY": "key1"}, []string{"sh", "-c", script})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "key1" {
+		t.Errorf("unexpected content read by the command: %q", b)
+	}
+	dir, err := os.ReadFile(out + ".dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(strings.TrimSpace(string(dir))); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("directory is not removed after the command exits: %v", err)
+	}
+}
+
+func TestFilesOptionsValidate(t *testing.T) {
+	if err := (FilesOptions{Files: true, FilesEnv: "CREDENTIALS_DIRECTORY"}).validate(nil); err == nil {
+		t.Error("expected error without a command")
+	}
+	if err := (FilesOptions{Files: true, FilesEnv: "BAD-NAME"}).validate([]string{"true"}); err == nil {
+		t.Error("expected error for an invalid environment variable name")
+	}
+	if err := (FilesOptions{Files: true, FilesEnv: "CREDENTIALS_DIRECTORY"}).validate([]string{"true"}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,253 @@
+package sscli
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SecretRef is a reference to a secret with options to export it.
+//
+// The syntax is
+//
+//	[ENV_VAR=]name[@version][?option=value&...][#key]
+//
+// where the options are version, format, prefix, separator and arrays, like 'name@3?format=json&prefix=DB_'.
+// The colon separated syntax 'name[:version[:format[:prefix]]]' is also accepted.
+// A backslash escapes the next character, like 'my\:name'.
+// Names containing any of '=:@?#\' must be escaped.
+type SecretRef struct {
+	// Env is the environment variable name, if set explicitly.
+	Env string
+	// Name of the secret.
+	Name string
+	// Version of the secret. 0 means the latest version.
+	Version int
+	// Format of the value to expand to an environment variable per key, like "json". Empty means no expansion.
+	Format string
+	// Prefix is added to the environment variable names.
+	Prefix string
+	// Separator joins keys of nested objects. Empty means DefaultExpandSeparator.
+	Separator string
+	// Arrays is how to expand arrays, ArraysIndex (default) or ArraysJSON.
+	Arrays string
+	// Key picks the single key from the expanded value (in JSON unless Format is set).
+	Key string
+}
+
+// SecretRefFormats are the formats of values that can be expanded.
+var SecretRefFormats = []string{"json", "yaml", "toml", "dotenv"}
+
+// SecretRefError is an error of parsing a SecretRef.
+type SecretRefError struct {
+	Input string
+	// Pos is the byte offset in Input where the error is found.
+	Pos int
+	Msg string
+}
+
+func (e *SecretRefError) Error() string {
+	return fmt.Sprintf("invalid secret reference %q at column %d: %s", e.Input, e.Pos+1, e.Msg)
+}
+
+// secretRefScanner scans a secret reference. Backslashes escape the next character.
+type secretRefScanner struct {
+	input string
+	pos   int
+}
+
+func (s *secretRefScanner) errorf(pos int, format string, args ...any) error {
+	return &SecretRefError{Input: s.input, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// scan returns the unescaped text until one of delims, and the delimiter (0 at the end of the input).
+// The position of the delimiter is consumed.
+func (s *secretRefScanner) scan(delims string) (text string, start int, delim byte, err error) {
+	start = s.pos
+	var b strings.Builder
+	for s.pos < len(s.input) {
+		c := s.input[s.pos]
+		switch {
+		case c == '\\':
+			if s.pos+1 >= len(s.input) {
+				return "", start, 0, s.errorf(s.pos, "trailing backslash")
+			}
+			b.WriteByte(s.input[s.pos+1])
+			s.pos += 2
+		case strings.IndexByte(delims, c) >= 0:
+			s.pos++
+			return b.String(), start, c, nil
+		default:
+			b.WriteByte(c)
+			s.pos++
+		}
+	}
+	return b.String(), start, 0, nil
+}
+
+// ParseSecretRef parses a reference to a secret like 'ENV_VAR=name@3?format=json&prefix=DB_#key'.
+func ParseSecretRef(input string) (*SecretRef, error) {
+	s := &secretRefScanner{input: input}
+	ref := &SecretRef{}
+
+	text, start, delim, err := s.scan("=:@?#")
+	if err != nil {
+		return nil, err
+	}
+	if delim == '=' {
+		if text == "" || EnvKeyInvalidRegex.MatchString(text) {
+			return nil, s.errorf(start, `invalid environment variable name %q (to use "=" in a name, escape it like \=)`, text)
+		}
+		ref.Env = text
+		text, start, delim, err = s.scan(":@?#")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if text == "" {
+		return nil, s.errorf(start, "name is required")
+	}
+	ref.Name = text
+
+	switch nameDelim, afterName := delim, s.pos; nameDelim {
+	case ':':
+		delim, err = s.parseColonFields(ref)
+	case '@', '?':
+		if nameDelim == '@' {
+			text, start, delim, err = s.scan("?#")
+			if err == nil {
+				ref.Version, err = s.parseVersion(text, start)
+			}
+		}
+		if err == nil && (nameDelim == '?' || delim == '?') {
+			delim, err = s.parseOptions(ref)
+		}
+		// names containing @ or ? were valid before the syntax was extended
+		var refErr *SecretRefError
+		if errors.As(err, &refErr) && refErr.Pos == afterName {
+			refErr.Msg += fmt.Sprintf(` (to use "%c" in a name, escape it like \%c)`, nameDelim, nameDelim)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if delim == '#' {
+		text, start, _, err = s.scan("")
+		if err != nil {
+			return nil, err
+		}
+		if text == "" {
+			return nil, s.errorf(start, "key is required after #")
+		}
+		ref.Key = text
+	}
+	if ref.Env != "" && ref.Format != "" && ref.Key == "" {
+		return nil, s.errorf(0, "environment variable name cannot be used with format, use prefix instead")
+	}
+	return ref, nil
+}
+
+// parseColonFields parses 'version[:format[:prefix]]' after 'name:'.
+func (s *secretRefScanner) parseColonFields(ref *SecretRef) (byte, error) {
+	text, start, delim, err := s.scan(":#")
+	if err != nil {
+		return 0, err
+	}
+	if text != "" {
+		v, err := strconv.Atoi(text)
+		if err != nil || v < 0 {
+			return 0, s.errorf(start, "invalid version %q", text)
+		}
+		ref.Version = v
+	}
+	if delim != ':' {
+		return delim, nil
+	}
+	text, start, delim, err = s.scan(":#")
+	if err != nil {
+		return 0, err
+	}
+	if err := s.setFormat(ref, text, start); err != nil {
+		return 0, err
+	}
+	if delim != ':' {
+		return delim, nil
+	}
+	text, _, delim, err = s.scan(":#")
+	if err != nil {
+		return 0, err
+	}
+	if delim == ':' {
+		return 0, s.errorf(s.pos-1, "too many fields")
+	}
+	ref.Prefix = text
+	return delim, nil
+}
+
+// parseOptions parses 'option=value&...' after '?'.
+func (s *secretRefScanner) parseOptions(ref *SecretRef) (byte, error) {
+	seen := make(map[string]bool)
+	for {
+		key, start, delim, err := s.scan("=&#")
+		if err != nil {
+			return 0, err
+		}
+		if delim != '=' {
+			return 0, s.errorf(start, "option must be like key=value")
+		}
+		if seen[key] {
+			return 0, s.errorf(start, "duplicate option %q", key)
+		}
+		seen[key] = true
+		value, vstart, delim, err := s.scan("&#")
+		if err != nil {
+			return 0, err
+		}
+		switch key {
+		case "version":
+			ref.Version, err = s.parseVersion(value, vstart)
+		case "format":
+			if value == "" {
+				err = s.errorf(vstart, "format is required")
+			} else {
+				err = s.setFormat(ref, value, vstart)
+			}
+		case "prefix":
+			ref.Prefix = value
+		case "separator":
+			ref.Separator = value
+		case "arrays":
+			if value != ArraysIndex && value != ArraysJSON {
+				err = s.errorf(vstart, "arrays must be %s or %s, got %q", ArraysIndex, ArraysJSON, value)
+			}
+			ref.Arrays = value
+		default:
+			err = s.errorf(start, "unknown option %q", key)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if delim != '&' {
+			return delim, nil
+		}
+	}
+}
+
+func (s *secretRefScanner) parseVersion(text string, start int) (int, error) {
+	v, err := strconv.Atoi(text)
+	if err != nil || v < 1 {
+		return 0, s.errorf(start, "version must be a positive integer, got %q", text)
+	}
+	return v, nil
+}
+
+func (s *secretRefScanner) setFormat(ref *SecretRef, text string, start int) error {
+	if text != "" && !slices.Contains(SecretRefFormats, text) {
+		return s.errorf(start, "unknown format %q (available: %s)", text, strings.Join(SecretRefFormats, ", "))
+	}
+	ref.Format = text
+	return nil
+}
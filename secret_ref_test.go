@@ -0,0 +1,120 @@
+package sscli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseSecretRef(t *testing.T) {
+	tests := []struct {
+		input string
+		want  SecretRef
+	}{
+		{input: "foo@3", want: SecretRef{Name: "foo", Version: 3}},
+		{input: "foo?format=json&prefix=DB_", want: SecretRef{Name: "foo", Format: "json", Prefix: "DB_"}},
+		{input: "foo@3?format=json&prefix=DB_", want: SecretRef{Name: "foo", Version: 3, Format: "json", Prefix: "DB_"}},
+		{input: "foo?version=2", want: SecretRef{Name: "foo", Version: 2}},
+		{input: "foo?prefix=A:B", want: SecretRef{Name: "foo", Prefix: "A:B"}},
+		{input: `foo::json:A\:B`, want: SecretRef{Name: "foo", Format: "json", Prefix: "A:B"}},
+		{input: `my\:name\@x:2`, want: SecretRef{Name: "my:name@x", Version: 2}},
+		{input: `foo?prefix=A\&B`, want: SecretRef{Name: "foo", Prefix: "A&B"}},
+		{input: "DB_PASS=db_creds#password", want: SecretRef{Env: "DB_PASS", Name: "db_creds", Key: "password"}},
+		{input: "DB_PASS=db_creds@2#password", want: SecretRef{Env: "DB_PASS", Name: "db_creds", Version: 2, Key: "password"}},
+		{input: "db_creds:2#pass#word", want: SecretRef{Name: "db_creds", Version: 2, Key: "pass#word"}},
+		{input: "foo?prefix=P_#key", want: SecretRef{Name: "foo", Prefix: "P_", Key: "key"}},
+		{input: "foo?format=yaml#key", want: SecretRef{Name: "foo", Format: "yaml", Key: "key"}},
+		{input: "foo?format=json&separator=__&arrays=json", want: SecretRef{Name: "foo", Format: "json", Separator: "__", Arrays: "json"}},
+		{input: "foo::dotenv:APP_", want: SecretRef{Name: "foo", Format: "dotenv", Prefix: "APP_"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSecretRef(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != tt.want {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSecretRefErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{input: "", pos: 0},
+		{input: "@3", pos: 0},
+		{input: "DB-PASS=foo", pos: 0},
+		{input: "foo:1:notjson", pos: 6},
+		{input: "foo:abc", pos: 4},
+		{input: "foo:1:json:prefix:extra", pos: 17},
+		{input: "foo@", pos: 4},
+		{input: "foo@0", pos: 4},
+		{input: "foo@x?format=json", pos: 4},
+		{input: "foo?format=yml", pos: 11},
+		{input: "foo?format=json&unknown=1", pos: 16},
+		{input: "foo?prefix=A&prefix=B", pos: 13},
+		{input: "foo?json", pos: 4},
+		{input: "foo#", pos: 4},
+		{input: `foo\`, pos: 3},
+		{input: "foo?arrays=flat", pos: 11},
+		{input: "FOO=foo::json", pos: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ParseSecretRef(tt.input)
+			var refErr *SecretRefError
+			if !errors.As(err, &refErr) {
+				t.Fatalf("expected SecretRefError, got %v", err)
+			}
+			if refErr.Pos != tt.pos {
+				t.Errorf("pos = %d, want %d: %s", refErr.Pos, tt.pos, err)
+			}
+		})
+	}
+}
+
+func TestParseSecretRefEscapeHint(t *testing.T) {
+	for _, input := range []string{"foo@bar", "foo?bar", "a-b=foo"} {
+		_, err := ParseSecretRef(input)
+		if err == nil || !strings.Contains(err.Error(), "escape it like") {
+			t.Errorf("expected error with the escape hint for %q, got %v", input, err)
+		}
+	}
+	if _, err := ParseSecretRef("foo?format=yml"); err == nil || strings.Contains(err.Error(), "escape it like") {
+		t.Errorf("unexpected escape hint for an invalid option: %v", err)
+	}
+}
+
+func TestParseGetName(t *testing.T) {
+	tests := []struct {
+		input   string
+		name    string
+		version int
+	}{
+		{input: "foo", name: "foo"},
+		{input: "foo:3", name: "foo", version: 3},
+		{input: "foo@3", name: "foo", version: 3},
+		{input: "foo?version=3", name: "foo", version: 3},
+		{input: `foo\#bar\@baz`, name: "foo#bar@baz"},
+		{input: `ENV\=foo`, name: "ENV=foo"},
+	}
+	for _, tt := range tests {
+		name, version, err := parseGetName(tt.input)
+		if err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if name != tt.name || version != tt.version {
+			t.Errorf("%s: got %q %d, want %q %d", tt.input, name, version, tt.name, tt.version)
+		}
+	}
+	for _, input := range []string{"ENV=foo", "foo#bar", "foo::json", "foo?prefix=P_", "foo?format=json"} {
+		if _, _, err := parseGetName(input); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
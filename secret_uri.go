@@ -0,0 +1,85 @@
+package sscli
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SecretURIScheme is the scheme of references to secrets like
+// sakura://VAULT_ID/name?version=3#json.key
+const SecretURIScheme = "sakura"
+
+// SecretURIRegex matches references to secrets in text.
+// A reference ends at whitespace, quotes or brackets, and does not include
+// trailing punctuation like "." or ":", as in "see sakura://VAULT_ID/name.".
+// A reference escaped with a backslash like \sakura://VAULT_ID/name is matched with the backslash,
+// to be written without the backslash and not resolved.
+var SecretURIRegex = regexp.MustCompile(`\\?sakura://(?:[A-Za-z0-9._~%/?#=&+:@-]*[A-Za-z0-9_~%/=+@-])?`)
+
+// secretURIEscape escapes a reference to be written literally.
+const secretURIEscape = `\`
+
+// SecretURI is a reference to a secret.
+type SecretURI struct {
+	// VaultID is empty if the reference has no vault like sakura:///name.
+	VaultID string
+	Name    string
+	// Version 0 means the latest version.
+	Version int
+	// JSONKey is the key in the JSON object of the secret value, if not empty.
+	JSONKey string
+}
+
+// ParseSecretURI parses a reference to a secret like sakura://VAULT_ID/name?version=3#json.key.
+func ParseSecretURI(s string) (*SecretURI, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret URI %q: %w", s, err)
+	}
+	if u.Scheme != SecretURIScheme {
+		return nil, fmt.Errorf("invalid secret URI %q: scheme must be %s", s, SecretURIScheme)
+	}
+	if u.User != nil || u.Port() != "" {
+		return nil, fmt.Errorf("invalid secret URI %q: unexpected user or port", s)
+	}
+	name := strings.TrimPrefix(u.Path, "/")
+	if name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid secret URI %q: path must be a secret name", s)
+	}
+	ref := &SecretURI{VaultID: u.Host, Name: name}
+	for k, vs := range u.Query() {
+		switch k {
+		case "version":
+			v, err := strconv.Atoi(vs[len(vs)-1])
+			if err != nil || v < 1 {
+				return nil, fmt.Errorf("invalid secret URI %q: version must be a positive integer", s)
+			}
+			ref.Version = v
+		default:
+			return nil, fmt.Errorf("invalid secret URI %q: unknown parameter %s", s, k)
+		}
+	}
+	if u.Fragment != "" {
+		key, ok := strings.CutPrefix(u.Fragment, "json.")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid secret URI %q: fragment must be like #json.key", s)
+		}
+		ref.JSONKey = key
+	}
+	return ref, nil
+}
+
+// String returns the reference in the URI form.
+func (r SecretURI) String() string {
+	u := url.URL{Scheme: SecretURIScheme, Host: r.VaultID, Path: "/" + r.Name}
+	if r.Version > 0 {
+		u.RawQuery = url.Values{"version": {strconv.Itoa(r.Version)}}.Encode()
+	}
+	if r.JSONKey != "" {
+		u.Fragment = "json." + r.JSONKey
+	}
+	return u.String()
+}
@@ -0,0 +1,67 @@
+package sscli
+
+import (
+	"testing"
+)
+
+func TestParseSecretURI(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    SecretURI
+		wantErr bool
+	}{
+		{input: "sakura://vault1/foo", want: SecretURI{VaultID: "vault1", Name: "foo"}},
+		{input: "sakura://vault1/foo?version=3", want: SecretURI{VaultID: "vault1", Name: "foo", Version: 3}},
+		{input: "sakura://vault1/foo#json.key", want: SecretURI{VaultID: "vault1", Name: "foo", JSONKey: "key"}},
+		{input: "sakura://vault1/foo?version=3#json.db.host", want: SecretURI{VaultID: "vault1", Name: "foo", Version: 3, JSONKey: "db.host"}},
+		{input: "sakura:///foo", want: SecretURI{Name: "foo"}},
+		{input: "https://vault1/foo", wantErr: true},
+		{input: "sakura://vault1/", wantErr: true},
+		{input: "sakura://vault1/foo/bar", wantErr: true},
+		{input: "sakura://vault1/foo?version=0", wantErr: true},
+		{input: "sakura://vault1/foo?version=x", wantErr: true},
+		{input: "sakura://vault1/foo?ver=1", wantErr: true},
+		{input: "sakura://vault1/foo#key", wantErr: true},
+		{input: "sakura://vault1/foo#json.", wantErr: true},
+		{input: "sakura://user@vault1/foo", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSecretURI(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != tt.want {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+			if s := got.String(); s != tt.input {
+				t.Errorf("String() = %s, want %s", s, tt.input)
+			}
+		})
+	}
+}
+
+func TestSecretURIRegex(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"sakura://vault1/foo", "sakura://vault1/foo"},
+		{"see sakura://vault1/foo.", "sakura://vault1/foo"},
+		{"(sakura://vault1/foo?version=3), next", "sakura://vault1/foo?version=3"},
+		{"sakura://vault1/foo#json.db.host:", "sakura://vault1/foo#json.db.host"},
+		{`"sakura:///foo"`, "sakura:///foo"},
+		{`\sakura://vault1/foo.`, `\sakura://vault1/foo`},
+	}
+	for _, tt := range tests {
+		if got := SecretURIRegex.FindString(tt.text); got != tt.want {
+			t.Errorf("FindString(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
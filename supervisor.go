@@ -0,0 +1,96 @@
+package sscli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+)
+
+// RunOptions are options to run a command with secrets.
+type RunOptions struct {
+	Supervise bool `help:"Run the command as a child process and supervise it, instead of replacing this process (default on Windows)" default:"${default_supervise}" negatable:""`
+}
+
+// defaultSupervise is the default of --supervise. Windows cannot replace the process.
+var defaultSupervise = runtime.GOOS == "windows"
+
+// ExitError is returned when a supervised command exits with a non-zero code.
+// The CLI exits with the same code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("command exited with code %d", e.Code)
+}
+
+// childProcess is a supervised child process.
+type childProcess struct {
+	cmd *exec.Cmd
+	// done is closed when the child exits.
+	done chan struct{}
+	code int
+	err  error
+}
+
+// startChild starts the command as a child process. done is closed when it exits.
+func startChild(bin string, command []string, env []string) (*childProcess, error) {
+	cmd := &exec.Cmd{
+		Path:   bin,
+		Args:   command,
+		Env:    env,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", bin, err)
+	}
+	c := &childProcess{cmd: cmd, done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		c.code, c.err = waitChild(cmd)
+	}()
+	return c, nil
+}
+
+// result returns nil if the exited child succeeded, or ExitError with its exit code.
+// When the child is killed by a signal, the exit code is 128 + the signal number like shells.
+func (c *childProcess) result() error {
+	if c.err != nil {
+		return fmt.Errorf("failed to wait for %s: %w", c.cmd.Path, c.err)
+	}
+	if c.code != 0 {
+		return &ExitError{Code: c.code}
+	}
+	return nil
+}
+
+// notifyForwardedSignals returns a channel that receives signals to forward to children.
+func notifyForwardedSignals() chan os.Signal {
+	sigCh := make(chan os.Signal, 8)
+	signal.Notify(sigCh, forwardedSignals...)
+	return sigCh
+}
+
+// runSupervised runs the command as a child process, forwards signals to it and waits for it to exit.
+func runSupervised(bin string, command []string, env []string) error {
+	// catch signals before starting the child not to miss them
+	sigCh := notifyForwardedSignals()
+	defer signal.Stop(sigCh)
+
+	child, err := startChild(bin, command, env)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case sig := <-sigCh:
+			forwardSignal(child.cmd.Process, sig)
+		case <-child.done:
+			return child.result()
+		}
+	}
+}
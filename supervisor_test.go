@@ -0,0 +1,60 @@
+//go:build !windows
+
+package sscli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunSupervisedExitCode(t *testing.T) {
+	err := runSupervised("/bin/sh", []string{"sh", "-c", "exit 3"}, os.Environ())
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("expected ExitError, got %v", err)
+	}
+	if exitErr.Code != 3 {
+		t.Errorf("unexpected exit code: %d", exitErr.Code)
+	}
+
+	if err := runSupervised("/bin/sh", []string{"sh", "-c", "exit 0"}, os.Environ()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRunSupervisedForwardSignals(t *testing.T) {
+	ready := filepath.Join(t.TempDir(), "ready")
+	script := `trap 'exit 7' USR1; touch "$READY"; while :; do sleep 0.1; done`
+	go func() {
+		for range 50 {
+			if _, err := os.Stat(ready); err == nil {
+				syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+	err := runSupervised("/bin/sh", []string{"sh", "-c", script}, append(os.Environ(), "READY="+ready))
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("expected ExitError, got %v", err)
+	}
+	if exitErr.Code != 7 {
+		t.Errorf("unexpected exit code: %d", exitErr.Code)
+	}
+}
+
+func TestRunSupervisedKilled(t *testing.T) {
+	err := runSupervised("/bin/sh", []string{"sh", "-c", "kill -KILL $$"}, os.Environ())
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("expected ExitError, got %v", err)
+	}
+	if exitErr.Code != 128+int(syscall.SIGKILL) {
+		t.Errorf("unexpected exit code: %d", exitErr.Code)
+	}
+}
@@ -0,0 +1,83 @@
+//go:build !windows
+
+package sscli
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// forwardedSignals are forwarded to the supervised child.
+var forwardedSignals = []os.Signal{unix.SIGINT, unix.SIGTERM, unix.SIGHUP, unix.SIGUSR1, unix.SIGUSR2}
+
+// stopSignal asks the child to exit gracefully.
+var stopSignal os.Signal = unix.SIGTERM
+
+// parseSignal parses a signal name like HUP or SIGHUP.
+func parseSignal(name string) (os.Signal, error) {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig := unix.SignalNum(name)
+	if sig == 0 {
+		return nil, fmt.Errorf("unknown signal %s", name)
+	}
+	return sig, nil
+}
+
+func forwardSignal(p *os.Process, sig os.Signal) {
+	if err := p.Signal(sig); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		slog.Warn("failed to forward signal", "signal", sig, "error", err)
+	}
+}
+
+// waitChild waits for the child to exit and returns the exit code.
+// As PID 1 in a container, it also reaps orphaned processes re-parented to this process.
+func waitChild(cmd *exec.Cmd) (int, error) {
+	if os.Getpid() != 1 {
+		err := cmd.Wait()
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			return 0, err
+		}
+		return waitStatusCode(cmd.ProcessState.Sys().(syscall.WaitStatus)), nil
+	}
+
+	// catch SIGCHLD before the first wait not to miss exits
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, unix.SIGCHLD)
+	defer signal.Stop(sigCh)
+	for {
+		for {
+			var ws unix.WaitStatus
+			pid, err := unix.Wait4(-1, &ws, unix.WNOHANG, nil)
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			if err != nil || pid <= 0 {
+				break
+			}
+			if pid == cmd.Process.Pid {
+				cmd.Process.Release()
+				return waitStatusCode(syscall.WaitStatus(ws)), nil
+			}
+		}
+		<-sigCh
+	}
+}
+
+func waitStatusCode(ws syscall.WaitStatus) int {
+	if ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return ws.ExitStatus()
+}
@@ -0,0 +1,34 @@
+//go:build windows
+
+package sscli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// forwardedSignals are caught not to exit before the child.
+// The child in the same console receives Ctrl+C by itself, so they are not forwarded.
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+// stopSignal stops the child. Windows cannot ask a process to exit gracefully by a signal.
+var stopSignal = os.Kill
+
+// parseSignal returns an error because signals cannot be sent to processes on Windows.
+func parseSignal(name string) (os.Signal, error) {
+	return nil, fmt.Errorf("sending signal %s is not supported on Windows", name)
+}
+
+func forwardSignal(p *os.Process, sig os.Signal) {}
+
+// waitChild waits for the child to exit and returns the exit code.
+func waitChild(cmd *exec.Cmd) (int, error) {
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return 0, err
+	}
+	return cmd.ProcessState.ExitCode(), nil
+}
@@ -0,0 +1,45 @@
+package sscli
+
+import (
+	"context"
+	"fmt"
+
+	sm "github.com/sacloud/secretmanager-api-go"
+	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
+)
+
+type VaultCreateCommand struct {
+	Name        string   `help:"Name of the vault to create" required:""`
+	Description string   `help:"Description of the vault"`
+	KmsKeyID    string   `help:"KMS key ID to encrypt the vault" required:"" name:"kms-key-id"`
+	Tag         []string `help:"Tags of the vault"`
+}
+
+func runVaultCreateCommand(ctx context.Context, cli *CLI) error {
+	cmd := cli.Vault.Create
+	ctx, cancel := cli.APIOptions.withDeadline(ctx)
+	defer cancel()
+	client, err := newSMClient(cli.APIOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create SecretManager client: %w", err)
+	}
+	req := v1.CreateVault{
+		Name:     cmd.Name,
+		KmsKeyID: cmd.KmsKeyID,
+		Tags:     cmd.Tag,
+	}
+	if cmd.Description != "" {
+		req.Description = v1.NewOptString(cmd.Description)
+	}
+	if req.Tags == nil {
+		req.Tags = []string{}
+	}
+
+	vaultOp := sm.NewVaultOp(client)
+	res, err := vaultOp.Create(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to create vault: %w", err)
+	}
+	fmt.Println(jsonString(res))
+	return nil
+}
@@ -0,0 +1,38 @@
+package sscli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Songmu/prompter"
+	sm "github.com/sacloud/secretmanager-api-go"
+)
+
+type VaultDeleteCommand struct {
+	ID    string `arg:"" help:"ID of the vault to delete"`
+	Force bool   `help:"Force delete without confirmation"`
+}
+
+func runVaultDeleteCommand(ctx context.Context, cli *CLI) error {
+	cmd := cli.Vault.Delete
+
+	if cmd.Force || prompter.YesNo(fmt.Sprintf("Are you sure you want to delete the vault '%s'?", cmd.ID), false) {
+		// proceed
+	} else {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	// the deadline starts after the confirmation, not to count the time to answer
+	ctx, cancel := cli.APIOptions.withDeadline(ctx)
+	defer cancel()
+	client, err := newSMClient(cli.APIOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create SecretManager client: %w", err)
+	}
+	vaultOp := sm.NewVaultOp(client)
+	if err := vaultOp.Delete(ctx, cmd.ID); err != nil {
+		return fmt.Errorf("failed to delete vault: %w", err)
+	}
+	return nil
+}
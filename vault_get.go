@@ -0,0 +1,29 @@
+package sscli
+
+import (
+	"context"
+	"fmt"
+
+	sm "github.com/sacloud/secretmanager-api-go"
+)
+
+type VaultGetCommand struct {
+	ID string `arg:"" help:"ID of the vault to get"`
+}
+
+func runVaultGetCommand(ctx context.Context, cli *CLI) error {
+	cmd := cli.Vault.Get
+	ctx, cancel := cli.APIOptions.withDeadline(ctx)
+	defer cancel()
+	client, err := newSMClient(cli.APIOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create SecretManager client: %w", err)
+	}
+	vaultOp := sm.NewVaultOp(client)
+	res, err := vaultOp.Read(ctx, cmd.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get vault: %w", err)
+	}
+	fmt.Println(jsonString(res))
+	return nil
+}
@@ -0,0 +1,43 @@
+package sscli
+
+import (
+	"context"
+	"fmt"
+
+	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
+)
+
+type VaultListCommand struct{}
+
+func runVaultListCommand(ctx context.Context, cli *CLI) error {
+	ctx, cancel := cli.APIOptions.withDeadline(ctx)
+	defer cancel()
+	client, err := newSMClient(cli.APIOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create SecretManager client: %w", err)
+	}
+	res, err := listVaults(ctx, client)
+	if err != nil {
+		return fmt.Errorf("failed to list vaults: %w", err)
+	}
+	for _, v := range res {
+		fmt.Println(jsonString(v))
+	}
+	return nil
+}
+
+// listVaults returns all vaults, following pages.
+func listVaults(ctx context.Context, client *v1.Client) ([]v1.Vault, error) {
+	var vaults []v1.Vault
+	for {
+		res, err := client.SecretmanagerVaultsList(withPageFrom(ctx, len(vaults)))
+		if err != nil {
+			return nil, err
+		}
+		vaults = append(vaults, res.Vaults...)
+		total, ok := res.Total.Get()
+		if !ok || len(res.Vaults) == 0 || len(vaults) >= total {
+			return vaults, nil
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package sscli
+
+import (
+	"context"
+	"fmt"
+
+	sm "github.com/sacloud/secretmanager-api-go"
+	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
+)
+
+type VaultUpdateCommand struct {
+	ID          string   `arg:"" help:"ID of the vault to update"`
+	Name        *string  `help:"New name of the vault"`
+	Description *string  `help:"New description of the vault"`
+	Tag         []string `help:"New tags of the vault (replaces existing tags)" xor:"tag"`
+	ClearTags   bool     `help:"Remove all tags from the vault" xor:"tag"`
+}
+
+func runVaultUpdateCommand(ctx context.Context, cli *CLI) error {
+	cmd := cli.Vault.Update
+	ctx, cancel := cli.APIOptions.withDeadline(ctx)
+	defer cancel()
+	client, err := newSMClient(cli.APIOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create SecretManager client: %w", err)
+	}
+	vaultOp := sm.NewVaultOp(client)
+
+	// The API replaces the whole vault, so start from the current state
+	// and apply only the fields given on the command line.
+	current, err := vaultOp.Read(ctx, cmd.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get vault: %w", err)
+	}
+	req := v1.Vault{
+		Name:        current.Name,
+		Description: current.Description,
+		Tags:        current.Tags,
+	}
+	if cmd.Name != nil {
+		req.Name = *cmd.Name
+	}
+	if cmd.Description != nil {
+		req.Description = v1.NewOptString(*cmd.Description)
+	}
+	if len(cmd.Tag) > 0 {
+		req.Tags = cmd.Tag
+	}
+	if cmd.ClearTags || req.Tags == nil {
+		req.Tags = []string{}
+	}
+
+	res, err := vaultOp.Update(ctx, cmd.ID, req)
+	if err != nil {
+		return fmt.Errorf("failed to update vault: %w", err)
+	}
+	fmt.Println(jsonString(res))
+	return nil
+}
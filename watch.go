@@ -0,0 +1,292 @@
+package sscli
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
+	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
+
+	"github.com/fujiwara/sakura-secrets-cli/internal/atomicfile"
+)
+
+// WatchOptions are options of secret export to apply changes of the secrets.
+type WatchOptions struct {
+	WatchInterval    time.Duration `help:"Poll the latest versions of the secrets at the interval, and restart the command (and rewrite --output) when they change. 0 disables watching" placeholder:"DURATION"`
+	WatchSignal      string        `help:"Send the signal (like HUP) to the command after rewriting --output or --files instead of restarting it" placeholder:"SIGNAL"`
+	WatchStopTimeout time.Duration `help:"Time to wait for the command to exit on restart before killing it" default:"10s"`
+}
+
+const (
+	// watchJitter is the ratio of the random jitter added to the watch interval,
+	// not to poll in lockstep with other processes.
+	watchJitter = 0.2
+	// maxWatchBackoffShift limits the backoff after failures to 2^maxWatchBackoffShift times the interval.
+	maxWatchBackoffShift = 4
+)
+
+// watchDelay returns the delay to the next poll. The interval is doubled for each consecutive failure,
+// and jittered by ±watchJitter with rnd in [0, 1).
+func watchDelay(interval time.Duration, failures int, rnd float64) time.Duration {
+	d := interval << min(failures, maxWatchBackoffShift)
+	return time.Duration(float64(d) * (1 + watchJitter*(2*rnd-1)))
+}
+
+// watchKey identifies a watched secret.
+type watchKey struct {
+	VaultID string
+	Name    string
+}
+
+func (k watchKey) String() string {
+	return k.VaultID + "/" + k.Name
+}
+
+// exportWatcher polls the latest versions of the secrets to export, and exports them again when changed.
+type exportWatcher struct {
+	cmd     *ExportCommand
+	vaultID string
+	secrets []ExportSecret
+	client  *v1.Client
+	api     APIOptions
+	// versions are the latest versions of the watched secrets. 0 means the secret does not exist.
+	versions map[watchKey]int
+	envs     map[string]string
+}
+
+// watchKeys returns the secrets to watch. Secrets pinned to a version never change.
+func (w *exportWatcher) watchKeys() []watchKey {
+	var keys []watchKey
+	for _, s := range w.secrets {
+		if s.Version > 0 {
+			continue
+		}
+		keys = append(keys, watchKey{VaultID: cmp.Or(s.VaultID, w.vaultID), Name: s.Name})
+	}
+	return keys
+}
+
+// latestVersions lists the secrets in each vault and returns the latest versions of the watched secrets.
+func (w *exportWatcher) latestVersions(ctx context.Context) (map[watchKey]int, error) {
+	versions := make(map[watchKey]int)
+	listed := make(map[string]map[string]int)
+	for _, key := range w.watchKeys() {
+		latest, ok := listed[key.VaultID]
+		if !ok {
+			secrets, err := listSecrets(ctx, w.client, key.VaultID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list secrets in vault %s: %w", key.VaultID, err)
+			}
+			latest = make(map[string]int, len(secrets))
+			for _, s := range secrets {
+				latest[s.Name] = s.LatestVersion
+			}
+			listed[key.VaultID] = latest
+		}
+		versions[key] = latest[key.Name]
+	}
+	return versions, nil
+}
+
+// export exports the secrets and writes them to --output if set.
+func (w *exportWatcher) export(ctx context.Context) (map[string]string, error) {
+	envs, err := ExportSecrets(ctx, w.vaultID, w.secrets, w.cmd.exportOptions(w.api)...)
+	if err != nil {
+		return nil, err
+	}
+	if w.cmd.Output != "" {
+		var buf bytes.Buffer
+		if err := w.cmd.write(&buf, envs); err != nil {
+			return nil, err
+		}
+		if err := atomicfile.WriteFile(w.cmd.Output, buf.Bytes(), 0600); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", w.cmd.Output, err)
+		}
+	}
+	return envs, nil
+}
+
+// init takes the latest versions and exports the secrets for the first time, within the deadline.
+func (w *exportWatcher) init(ctx context.Context) error {
+	ctx, cancel := w.api.withDeadline(ctx)
+	defer cancel()
+	var err error
+	// take the versions before exporting, not to miss changes during the export
+	if w.versions, err = w.latestVersions(ctx); err != nil {
+		return err
+	}
+	w.envs, err = w.export(ctx)
+	return err
+}
+
+// poll checks the latest versions of the secrets, and exports them again if changed.
+// It reports whether the exported environment variables are changed.
+func (w *exportWatcher) poll(ctx context.Context) (bool, error) {
+	ctx, cancel := w.api.withDeadline(ctx)
+	defer cancel()
+	versions, err := w.latestVersions(ctx)
+	if err != nil {
+		return false, err
+	}
+	var changed []string
+	for key, v := range versions {
+		if w.versions[key] != v {
+			changed = append(changed, key.String())
+		}
+	}
+	if len(changed) == 0 {
+		return false, nil
+	}
+	slices.Sort(changed)
+	slog.Info("secrets changed", "secrets", strings.Join(changed, ","))
+	envs, err := w.export(ctx)
+	if err != nil {
+		// keep the old versions to retry on the next poll
+		return false, err
+	}
+	w.versions = versions
+	if maps.Equal(w.envs, envs) {
+		return false, nil
+	}
+	w.envs = envs
+	return true, nil
+}
+
+// runExportWatch exports the secrets and runs the command, and applies changes of the secrets until the command exits.
+// Without a command, it keeps rewriting --output until ctx is done.
+func runExportWatch(ctx context.Context, cli *CLI, secrets []ExportSecret) error {
+	cmd := &cli.Secret.Export
+	if len(cmd.Commands) == 0 && cmd.Output == "" {
+		return fmt.Errorf("--watch-interval requires a command or --output")
+	}
+	var reload os.Signal
+	if cmd.WatchSignal != "" {
+		if len(cmd.Commands) == 0 || (cmd.Output == "" && !cmd.Files) {
+			return fmt.Errorf("--watch-signal requires a command and --output or --files")
+		}
+		var err error
+		if reload, err = parseSignal(cmd.WatchSignal); err != nil {
+			return err
+		}
+	}
+	client, err := newSMClient(cli.APIOptions)
+	if err != nil {
+		return fmt.Errorf("failed to create SecretManager client: %w", err)
+	}
+	w := &exportWatcher{cmd: cmd, vaultID: cli.Secret.VaultID, secrets: secrets, client: client, api: cli.APIOptions}
+	if err := w.init(ctx); err != nil {
+		return err
+	}
+
+	var (
+		bin   string
+		child *childProcess
+		// nil channels block forever without a command
+		sigCh     chan os.Signal
+		childDone <-chan struct{}
+	)
+	childEnv := func() []string {
+		return mergeEnvs(os.Environ(), envList(w.envs))
+	}
+	var files *secretFiles
+	if cmd.Files {
+		if files, err = newSecretFiles(cmd.FilesDir); err != nil {
+			return err
+		}
+		defer files.removeLogged()
+		if err := files.write(w.envs); err != nil {
+			return err
+		}
+		childEnv = func() []string {
+			return mergeEnvs(os.Environ(), []string{cmd.FilesEnv + "=" + files.dir})
+		}
+	} else if len(cmd.Commands) > 0 {
+		if shadowed := shadowedEnvs(os.Environ(), w.envs); len(shadowed) > 0 {
+			slog.Warn("exported secrets override existing environment variables", "names", strings.Join(shadowed, ","))
+		}
+	}
+	if len(cmd.Commands) > 0 {
+		if bin, err = exec.LookPath(cmd.Commands[0]); err != nil {
+			return fmt.Errorf("command is not executable %s: %w", cmd.Commands[0], err)
+		}
+		sigCh = notifyForwardedSignals()
+		defer signal.Stop(sigCh)
+		if child, err = startChild(bin, cmd.Commands, childEnv()); err != nil {
+			return err
+		}
+		childDone = child.done
+	}
+
+	failures := 0
+	timer := time.NewTimer(watchDelay(cmd.WatchInterval, failures, rand.Float64()))
+	defer timer.Stop()
+	timerC, ctxDone := timer.C, ctx.Done()
+	for {
+		select {
+		case sig := <-sigCh:
+			forwardSignal(child.cmd.Process, sig)
+		case <-childDone:
+			return child.result()
+		case <-ctxDone:
+			if child == nil {
+				return nil
+			}
+			// stop watching, and wait for the child to exit by the forwarded signal
+			timerC, ctxDone = nil, nil
+		case <-timerC:
+			changed, err := w.poll(ctx)
+			if err != nil {
+				failures++
+				slog.Warn("failed to watch secrets", "error", err)
+			} else {
+				failures = 0
+			}
+			if changed && files != nil {
+				if err := files.write(w.envs); err != nil {
+					slog.Warn("failed to write secret files", "error", err)
+				}
+			}
+			if changed && child != nil {
+				if reload != nil {
+					slog.Info("sending signal to the command", "signal", reload)
+					forwardSignal(child.cmd.Process, reload)
+				} else {
+					slog.Info("restarting the command")
+					stopChild(child, cmd.WatchStopTimeout)
+					if child, err = startChild(bin, cmd.Commands, childEnv()); err != nil {
+						return err
+					}
+					childDone = child.done
+				}
+			}
+			timer.Reset(watchDelay(cmd.WatchInterval, failures, rand.Float64()))
+		}
+	}
+}
+
+// stopChild asks the child to exit, and kills it if it does not exit in timeout.
+func stopChild(child *childProcess, timeout time.Duration) {
+	if err := child.cmd.Process.Signal(stopSignal); err != nil {
+		slog.Warn("failed to stop the command", "error", err)
+	}
+	select {
+	case <-child.done:
+		return
+	case <-time.After(timeout):
+	}
+	slog.Warn("killing the command not exited in time", "timeout", timeout)
+	if err := child.cmd.Process.Kill(); err != nil {
+		slog.Warn("failed to kill the command", "error", err)
+	}
+	<-child.done
+}
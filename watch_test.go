@@ -0,0 +1,83 @@
+package sscli
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestWatchDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		rnd      float64
+		want     time.Duration
+	}{
+		{0, 0.5, 10 * time.Second},
+		{0, 0, 8 * time.Second},
+		{0, 1, 12 * time.Second},
+		{1, 0.5, 20 * time.Second},
+		{3, 0.5, 80 * time.Second},
+		{10, 0.5, 160 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := watchDelay(10*time.Second, tt.failures, tt.rnd); got != tt.want {
+			t.Errorf("watchDelay(10s, %d, %v) = %s, want %s", tt.failures, tt.rnd, got, tt.want)
+		}
+	}
+}
+
+func TestExportWatcherPoll(t *testing.T) {
+	newTestServer(t)
+	createTestSecrets(t, map[string][]string{
+		"api_key": {"key1"},
+		"pinned":  {"p1"},
+	})
+	ctx := t.Context()
+	client, err := newSMClient(DefaultAPIOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &exportWatcher{
+		cmd:     &ExportCommand{OnConflict: OnConflictError, Concurrency: DefaultExportConcurrency},
+		vaultID: testVaultID,
+		secrets: []ExportSecret{
+			{Name: "api_key"},
+			{Name: "pinned", Version: 1},
+			{Name: "later", Optional: true},
+		},
+		client: client,
+	}
+	if w.versions, err = w.latestVersions(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if w.envs, err = w.export(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := w.poll(ctx); err != nil || changed {
+		t.Fatalf("unexpected change without updates: %v, %v", changed, err)
+	}
+
+	// the pinned secret is not watched
+	createTestSecrets(t, map[string][]string{"pinned": {"p2"}})
+	if changed, err := w.poll(ctx); err != nil || changed {
+		t.Fatalf("unexpected change of the pinned secret: %v, %v", changed, err)
+	}
+
+	createTestSecrets(t, map[string][]string{"api_key": {"key2"}})
+	if changed, err := w.poll(ctx); err != nil || !changed {
+		t.Fatalf("expected change of api_key: %v, %v", changed, err)
+	}
+	want := map[string]string{"API_KEY": "key2", "PINNED": "p1"}
+	if !maps.Equal(w.envs, want) {
+		t.Errorf("got %v, want %v", w.envs, want)
+	}
+
+	// an optional secret created later is exported
+	createTestSecrets(t, map[string][]string{"later": {"l1"}})
+	if changed, err := w.poll(ctx); err != nil || !changed {
+		t.Fatalf("expected change of later: %v, %v", changed, err)
+	}
+	if w.envs["LATER"] != "l1" {
+		t.Errorf("unexpected envs: %v", w.envs)
+	}
+}
@@ -0,0 +1,68 @@
+//go:build !windows
+
+package sscli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/sacloud/secretmanager-api-go/apis/v1"
+)
+
+func TestRunExportWatchRestart(t *testing.T) {
+	newTestServer(t)
+	createTestSecrets(t, map[string][]string{"api_key": {"v1"}})
+	out := filepath.Join(t.TempDir(), "out")
+	t.Setenv("OUT", out)
+
+	cli := &CLI{}
+	cli.Secret.VaultID = testVaultID
+	cli.Secret.Export = ExportCommand{
+		Name:        []string{"api_key"},
+		OnConflict:  OnConflictError,
+		Concurrency: DefaultExportConcurrency,
+		Commands: []string{"sh", "-c",
+			`echo "$API_KEY" >> "$OUT"; [ "$API_KEY" = v2 ] && exit 5; trap 'exit 0' TERM; while :; do sleep 0.1; done`},
+		WatchOptions: WatchOptions{WatchInterval: 100 * time.Millisecond, WatchStopTimeout: 5 * time.Second},
+	}
+	secrets, err := cli.Secret.Export.exportSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := newSMClient(DefaultAPIOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for range 50 {
+			if b, _ := os.ReadFile(out); string(b) == "v1\n" {
+				_, err := client.SecretmanagerVaultsSecretsCreate(t.Context(), &v1.WrappedCreateSecret{
+					Secret: v1.CreateSecret{Name: "api_key", Value: "v2"},
+				}, v1.SecretmanagerVaultsSecretsCreateParams{VaultResourceID: testVaultID})
+				if err != nil {
+					t.Error(err)
+				}
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+
+	// the restarted command exits with 5 after receiving the new value
+	err = runExportWatch(t.Context(), cli, secrets)
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 5 {
+		t.Fatalf("expected exit code 5, got %v", err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(string(b)); strings.Join(got, ",") != "v1,v2" {
+		t.Errorf("unexpected values seen by the command: %v", got)
+	}
+}